@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// adminUsers function lists every user, with the forms admins use to change their role or deactivate them
+func (app *Application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := app.users.List()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "admin.page.gohtml", &templateData{
+		Users: users,
+		Roles: models.Roles,
+	})
+}
+
+// adminActivateUser function reactivates a user, so they can log in again
+func (app *Application) adminActivateUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetActive(w, r, true)
+}
+
+// adminDeactivateUser function deactivates a user. They are logged out straight away, as the authenticate
+// middleware checks on each request that the user in the session is still active, and their API tokens stop
+// working.
+func (app *Application) adminDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetActive(w, r, false)
+}
+
+// The adminSetActive helper activates or deactivates the user identified by the id URL parameter
+func (app *Application) adminSetActive(w http.ResponseWriter, r *http.Request, active bool) {
+	id, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.users.SetActive(id, active)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	state := "deactivated"
+	if active {
+		state = "reactivated"
+	}
+	app.infoLog.Printf("User %d %s by admin %d", id, state, app.authenticatedUserID(r))
+	app.session.Put(r, "toast", fmt.Sprintf("User #%d has been %s.", id, state))
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// adminSetRole function changes the role of a user
+func (app *Application) adminSetRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	role := r.PostForm.Get("role")
+	if !models.ValidRole(role) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.users.SetRole(id, role)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.infoLog.Printf("User %d given the %s role by admin %d", id, role, app.authenticatedUserID(r))
+	app.session.Put(r, "toast", fmt.Sprintf("User #%d is now a %s.", id, role))
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// The adminTargetUser helper returns the ID from the id URL parameter of the admin user routes. Admins can't
+// deactivate themselves or change their own role, so there is always at least one admin left; if they try to,
+// they're sent back to the admin page with a message and ok is false.
+func (app *Application) adminTargetUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return 0, false
+	}
+
+	if id == app.authenticatedUserID(r) {
+		app.session.Put(r, "toast", "You can't deactivate yourself or change your own role.")
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return 0, false
+	}
+	return id, true
+}
+
+// adminDeleteSnippet function lets a moderator remove any snippet, whoever its author is. The snippet is removed
+// by its slug without checking whether the moderator can see it, as other users' private snippets are hidden from
+// them.
+func (app *Application) adminDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	err := app.snippets.DeleteBySlug(slug)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.infoLog.Printf("Snippet %s removed by moderator %d", slug, app.authenticatedUserID(r))
+	app.session.Put(r, "toast", "Snippet successfully removed!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
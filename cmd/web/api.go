@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/rlr524/snippetbox/pkg/forms"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The apiSnippet type is the JSON representation of a snippet returned by the API. It's kept separate from
+// models.Snippet so that what the API exposes is decided here rather than by the database model. The ID is the
+// snippet's slug, as the numeric IDs aren't given out.
+type apiSnippet struct {
+	ID         string    `json:"id"`
+	Author     string    `json:"author"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Format     string    `json:"format"`
+	Language   string    `json:"language"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+	Protected  bool      `json:"protected"`
+}
+
+// The newAPISnippet function returns the JSON representation of a snippet. The content of a password protected
+// snippet is left out unless locked is false, see snippetLocked.
+func newAPISnippet(s *models.Snippet, locked bool) apiSnippet {
+	a := apiSnippet{
+		ID:         s.Slug,
+		Author:     s.Author,
+		Title:      s.Title,
+		Content:    s.Content,
+		Format:     s.Format,
+		Language:   s.Language,
+		Created:    s.Created,
+		Expires:    s.Expires,
+		Visibility: s.Visibility,
+		Protected:  s.Protected(),
+	}
+	if locked {
+		a.Content = ""
+	}
+	return a
+}
+
+// The apiSnippetInput type holds the JSON request body for creating or updating a snippet. Expires is the number
+// of days until the snippet expires and is only used when creating a snippet. Format and visibility default to
+// code and public for a new snippet, and are left unchanged by an update if they aren't given. Password is also
+// left unchanged if it isn't given, and an empty password removes it. Language is detected from the content if
+// it isn't given.
+type apiSnippetInput struct {
+	Title      string  `json:"title"`
+	Content    string  `json:"content"`
+	Format     string  `json:"format"`
+	Language   string  `json:"language"`
+	Expires    int     `json:"expires"`
+	Visibility string  `json:"visibility"`
+	Password   *string `json:"password"`
+}
+
+// The apiError type is the JSON error body used by all API responses. Fields holds any form validation errors,
+// keyed by the name of the field.
+type apiError struct {
+	Error  string       `json:"error"`
+	Fields forms.Errors `json:"fields,omitempty"`
+}
+
+// The decodeSnippetInput helper reads the JSON request body and returns it as a forms.Form, so that the API can
+// be validated with exactly the same rules as the HTML forms.
+func decodeSnippetInput(w http.ResponseWriter, r *http.Request) (*forms.Form, error) {
+	// Limit the size of the request body to 1MB.
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	var input apiSnippetInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := url.Values{
+		"title":      {input.Title},
+		"content":    {input.Content},
+		"format":     {input.Format},
+		"language":   {input.Language},
+		"visibility": {input.Visibility},
+	}
+	if input.Expires != 0 {
+		data.Set("expires", strconv.Itoa(input.Expires))
+	}
+	if input.Password != nil {
+		if *input.Password == "" {
+			data.Set("remove_password", "true")
+		} else {
+			data.Set("password", *input.Password)
+		}
+	}
+	return forms.New(data), nil
+}
+
+// The apiFormErrors helper sends the validation errors for a form as a 422 Unprocessable Entity response.
+func (app *Application) apiFormErrors(w http.ResponseWriter, form *forms.Form) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, apiError{
+		Error:  http.StatusText(http.StatusUnprocessableEntity),
+		Fields: form.FormErrors,
+	})
+}
+
+// apiListSnippets returns the latest snippets, in the same way as the home page
+func (app *Application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Latest(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets := make([]apiSnippet, 0, len(s))
+	for _, snippet := range s {
+		snippets = append(snippets, newAPISnippet(snippet, app.snippetLocked(r, snippet)))
+	}
+	app.writeJSON(w, http.StatusOK, map[string]interface{}{"snippets": snippets})
+}
+
+// apiShowSnippet returns a specific snippet based on its slug
+func (app *Application) apiShowSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	s, err := app.snippets.GetBySlug(slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.redirectSnippetID(w, r, slug, "/api/v1/snippets/%s")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]interface{}{"snippet": newAPISnippet(s, app.snippetLocked(r, s))})
+}
+
+// apiCreateSnippet creates a new snippet owned by the authenticated user
+func (app *Application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	form, err := decodeSnippetInput(w, r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	validateNewSnippet(form)
+	if !form.Valid() {
+		app.apiFormErrors(w, form)
+		return
+	}
+
+	slug, err := app.snippets.Insert(form.Get("title"), form.Get("content"),
+		formValueOr(form, "format", models.FormatCode), formLanguage(form), form.Get("expires"),
+		formValueOr(form, "visibility", models.VisibilityPublic), form.Get("password"), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	s, err := app.snippets.GetBySlug(slug, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%s", slug))
+	app.writeJSON(w, http.StatusCreated, map[string]interface{}{"snippet": newAPISnippet(s, false)})
+}
+
+// apiUpdateSnippet replaces the title, content, format, language, visibility and password of a snippet. The
+// snippet is loaded by the requireSnippetOwner middleware, which also checks that the current user is its author.
+func (app *Application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	form, err := decodeSnippetInput(w, r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	validateSnippetEdit(form)
+	if !form.Valid() {
+		app.apiFormErrors(w, form)
+		return
+	}
+
+	err = app.snippets.Update(s.ID, form.Get("title"), form.Get("content"), formValueOr(form, "format", s.Format),
+		formLanguage(form), formValueOr(form, "visibility", s.Visibility), formPassword(form))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	s, err = app.snippets.Get(s.ID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]interface{}{"snippet": newAPISnippet(s, false)})
+}
+
+// apiDeleteSnippet removes a snippet owned by the authenticated user
+func (app *Application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestAPISnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	// API clients use tokens rather than the session, so none of the requests are logged in.
+	ts := newTestServer(t, app.routes())
+
+	user := func(name, email string) int {
+		id, err := app.users.Insert(name, email, "pa55word1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = app.users.Activate(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	aliceID := user("Alice", "alice@example.com")
+	bobID := user("Bob", "bob@example.com")
+
+	token := func(userID int, scopes ...string) string {
+		plaintext, err := app.tokens.Insert(userID, "Test token", scopes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plaintext
+	}
+	readToken := token(aliceID, models.ScopeSnippetsRead)
+	writeToken := token(aliceID, models.ScopeSnippetsRead, models.ScopeSnippetsWrite)
+	bobToken := token(bobID, models.ScopeSnippetsRead, models.ScopeSnippetsWrite)
+
+	insert := func(title, visibility string) string {
+		slug, err := app.snippets.Insert(title, "content", models.FormatCode, "plaintext", "7", visibility, "",
+			aliceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slug
+	}
+	public := insert("Public snippet", models.VisibilityPublic)
+	private := insert("Private snippet", models.VisibilityPrivate)
+	toDelete := insert("Snippet to delete", models.VisibilityPublic)
+
+	create := `{"title": "From the API", "content": "content", "expires": 7}`
+	update := `{"title": "Updated", "content": "new content"}`
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"List, anonymous", http.MethodGet, "/api/v1/snippets", "", "", http.StatusOK, "Public snippet"},
+		{"Show, read token", http.MethodGet, "/api/v1/snippets/" + public, readToken, "", http.StatusOK,
+			`"id":"` + public + `"`},
+		{"Private, anonymous", http.MethodGet, "/api/v1/snippets/" + private, "", "", http.StatusNotFound, ""},
+		{"Private, other user's token", http.MethodGet, "/api/v1/snippets/" + private, bobToken, "",
+			http.StatusNotFound, ""},
+		{"Private, owner's token", http.MethodGet, "/api/v1/snippets/" + private, readToken, "", http.StatusOK,
+			"Private snippet"},
+		{"Unknown token", http.MethodGet, "/api/v1/snippets", "NOTAREALTOKEN", "", http.StatusUnauthorized, ""},
+		{"Create, anonymous", http.MethodPost, "/api/v1/snippets", "", create, http.StatusUnauthorized, ""},
+		{"Create, read token", http.MethodPost, "/api/v1/snippets", readToken, create, http.StatusForbidden, ""},
+		{"Create, write token", http.MethodPost, "/api/v1/snippets", writeToken, create, http.StatusCreated,
+			"From the API"},
+		{"Create, not JSON", http.MethodPost, "/api/v1/snippets", writeToken, "", http.StatusUnsupportedMediaType,
+			""},
+		{"Create, invalid", http.MethodPost, "/api/v1/snippets", writeToken, `{"title": "", "expires": 7}`,
+			http.StatusUnprocessableEntity, "This field cannot be blank"},
+		{"Create, unknown field", http.MethodPost, "/api/v1/snippets", writeToken, `{"colour": "red"}`,
+			http.StatusBadRequest, ""},
+		{"Update, other user", http.MethodPut, "/api/v1/snippets/" + public, bobToken, update,
+			http.StatusForbidden, ""},
+		{"Update, read token", http.MethodPut, "/api/v1/snippets/" + public, readToken, update,
+			http.StatusForbidden, ""},
+		{"Update, owner", http.MethodPut, "/api/v1/snippets/" + public, writeToken, update, http.StatusOK,
+			"new content"},
+		{"Delete, other user", http.MethodDelete, "/api/v1/snippets/" + toDelete, bobToken, "",
+			http.StatusForbidden, ""},
+		{"Delete, owner", http.MethodDelete, "/api/v1/snippets/" + toDelete, writeToken, "", http.StatusNoContent,
+			""},
+		{"Deleted", http.MethodGet, "/api/v1/snippets/" + toDelete, writeToken, "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.request(t, tt.method, tt.urlPath, tt.token, tt.body)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, body)
+			}
+			// Every response with a body, errors included, is JSON.
+			ct := header.Get("Content-Type")
+			if code != http.StatusNoContent && ct != "application/json; charset=utf-8" {
+				t.Errorf("want JSON content type; got %q", ct)
+			}
+		})
+	}
+}
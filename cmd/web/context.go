@@ -0,0 +1,21 @@
+package main
+
+// Define a custom contextKey type so that the keys used to store values in the request context can't collide
+// with keys used by any third-party packages.
+type contextKey string
+
+// The contextKeySnippet key is used by the requireSnippetOwner middleware to pass the snippet it has already
+// loaded from the database on to the next handler in the chain.
+const contextKeySnippet = contextKey("snippet")
+
+// The contextKeyToken key is used by the authenticateToken middleware to store the personal access token that
+// was presented in the Authorization header, so the user ID and scopes are available to later handlers.
+const contextKeyToken = contextKey("token")
+
+// The contextKeyAuthenticatedUserID key is used by the authenticate middleware to store the ID of the logged in
+// user, once it has confirmed that the user in the session still exists and is active.
+const contextKeyAuthenticatedUserID = contextKey("authenticatedUserID")
+
+// The contextKeyAuthenticatedUserRole key is used by the authenticate middleware to store the role of the logged
+// in user, for the requireRole middleware and the templates.
+const contextKeyAuthenticatedUserRole = contextKey("authenticatedUserRole")
@@ -7,14 +7,20 @@ import (
 	"github.com/rlr524/snippetbox/pkg/forms"
 	"github.com/rlr524/snippetbox/pkg/models"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// Password reset tokens expire after an hour.
+const passwordResetTTL = time.Hour
+
 // The home function is defined as a method against *Application (a function receiver) (
 func (app *Application) home(w http.ResponseWriter, r *http.Request) {
-	s, err := app.snippets.Latest()
+	s, err := app.snippets.Latest(app.authenticatedUserID(r))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -25,28 +31,64 @@ func (app *Application) home(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *Application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	// Use the snippetFromURL helper to retrieve the data for a specific record based on the slug in its URL. If
+	// no matching record is found, it may be an old URL with the snippet's ID instead.
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s")
+	if !ok {
+		return
+	}
+
+	// If the snippet has a password which the user hasn't given yet, ask for it instead of showing the snippet.
+	if app.snippetLocked(r, s) {
+		app.render(w, r, "unlock.page.gohtml", &templateData{
+			Form:    forms.New(nil),
+			Snippet: s,
+		})
+		return
+	}
+
+	// If the snippet is a fork, look up the original too, as long as the user can still see it.
+	td := &templateData{Snippet: s}
+	if s.ParentID != 0 {
+		parent, err := app.snippets.Get(s.ParentID, app.authenticatedUserID(r))
+		if err == nil {
+			td.Parent = parent
+		} else if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Use the render helper.
+	app.render(w, r, "show.page.gohtml", td)
+}
+
+// The redirectSnippetID helper handles an old snippet URL, from before snippets had slugs, which has the
+// snippet's numeric ID in place of the slug. It redirects to the URL made from format and the snippet's slug, but
+// only for public snippets, so that the IDs can't be used to find unlisted or private ones. Anything else gets a
+// 404 Not Found response.
+func (app *Application) redirectSnippetID(w http.ResponseWriter, r *http.Request, param, format string) {
+	id, err := strconv.Atoi(param)
 	if err != nil || id < 1 {
-		app.notFound(w) // Use the notFound helper
+		app.notFound(w, r) // Use the notFound helper
 		return
 	}
 
-	// Use the SnippetModel object's Get method to retrieve the data for a specific record based
-	// on its ID. If no matching record is found, return a 404 Not Found response.
-	s, err := app.snippets.Get(id)
+	s, err := app.snippets.Get(id, app.authenticatedUserID(r))
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+			app.notFound(w, r)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
+	if s.Visibility != models.VisibilityPublic {
+		app.notFound(w, r)
+		return
+	}
 
-	// Use the render helper.
-	app.render(w, r, "show.page.gohtml", &templateData{
-		Snippet: s,
-	})
+	http.Redirect(w, r, fmt.Sprintf(format, s.Slug), http.StatusMovedPermanently)
 }
 
 // createSnippetForm function is a handler for presenting to form used to create a new snippet
@@ -56,21 +98,75 @@ func (app *Application) createSnippetForm(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// The validateNewSnippet function applies the validation rules for a new snippet to the form. The same rules are
+// used by both the HTML form and the JSON API.
+func validateNewSnippet(form *forms.Form) {
+	form.Required("title", "content", "expires")
+	form.MaxLength("title", 100)
+	form.PermittedValues("expires", "365", "7", "1")
+	form.PermittedValues("format", models.Formats...)
+	form.PermittedValues("language", models.Languages...)
+	form.PermittedValues("visibility", models.Visibilities...)
+	form.MaxLength("password", 72)
+}
+
+// The validateSnippetEdit function applies the validation rules for changes to an existing snippet to the form.
+func validateSnippetEdit(form *forms.Form) {
+	form.Required("title", "content")
+	form.MaxLength("title", 100)
+	form.PermittedValues("format", models.Formats...)
+	form.PermittedValues("language", models.Languages...)
+	form.PermittedValues("visibility", models.Visibilities...)
+	form.MaxLength("password", 72)
+}
+
+// The formLanguage function returns the language chosen in a validated snippet form or, if none was chosen,
+// the language detected from the content.
+func formLanguage(form *forms.Form) string {
+	if language := form.Get("language"); language != "" {
+		return language
+	}
+	return detectLanguage(form.Get("content"))
+}
+
+// The formValueOr function returns the value of a field in a validated snippet form, or fallback if the form
+// leaves it out. It's used for the format and visibility, which default to code and public for a new snippet and
+// are left unchanged by an edit that doesn't give them.
+func formValueOr(form *forms.Form, field, fallback string) string {
+	if v := form.Get(field); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// The formPassword function returns the password to give SnippetStore.Update for a validated snippet edit form. A
+// new password replaces the snippet's current one and remove_password takes it off; if neither is given, it's
+// nil and the password is left as it is.
+func formPassword(form *forms.Form) *string {
+	switch {
+	case form.Get("remove_password") != "":
+		none := ""
+		return &none
+	case form.Get("password") != "":
+		password := form.Get("password")
+		return &password
+	}
+	return nil
+}
+
 // createSnippet function creates a new snippet #docs.md: createSnippet
 func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// Call r.ParseForm which adds any data in POST request bodies to the r.PostForm map. This also works in the
 	// same way for PUT and PATCH requests. If there are any errors, us the app.ClientError helper to send a 400.
 	err := r.ParseForm()
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
 	// The forms.Form struct contains the POSTed data from the form, then uses the validation methods to check content.
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	validateNewSnippet(form)
 
 	// If the form isn't valid, redisplay the template passing in the form.Form object as the data.
 	if !form.Valid() {
@@ -81,10 +177,13 @@ func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Because the form data (with type url.Values) has been anonymously embedded in the form.Form struct,
-	// use the Get() method to retrieve the validated value for a particular form field.
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	// use the Get() method to retrieve the validated value for a particular form field. The snippet is owned
+	// by the currently authenticated user.
+	slug, err := app.snippets.Insert(form.Get("title"), form.Get("content"),
+		formValueOr(form, "format", models.FormatCode), formLanguage(form), form.Get("expires"),
+		formValueOr(form, "visibility", models.VisibilityPublic), form.Get("password"), app.authenticatedUserID(r))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -93,12 +192,111 @@ func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// new empty session for them will be automatically created by the session middleware.
 	app.session.Put(r, "toast", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s", slug), http.StatusSeeOther)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Add("Cache-Control", "public")
 }
 
+// editSnippetForm function is a handler for presenting the form used to edit an existing snippet. The snippet
+// is loaded by the requireSnippetOwner middleware, which also checks that the current user is its author.
+func (app *Application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	app.render(w, r, "edit.page.gohtml", &templateData{
+		Form: forms.New(url.Values{
+			"title":      {s.Title},
+			"content":    {s.Content},
+			"format":     {s.Format},
+			"language":   {s.Language},
+			"visibility": {s.Visibility},
+		}),
+		Snippet: s,
+	})
+}
+
+// editSnippet function updates the title, content, format, language, visibility and password of an existing snippet
+func (app *Application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form using the same rules as when the snippet was created.
+	form := forms.New(r.PostForm)
+	validateSnippetEdit(form)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.gohtml", &templateData{
+			Form:    form,
+			Snippet: s,
+		})
+		return
+	}
+
+	err = app.snippets.Update(s.ID, form.Get("title"), form.Get("content"), formValueOr(form, "format", s.Format),
+		formLanguage(form), formValueOr(form, "visibility", s.Visibility), formPassword(form))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "toast", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+}
+
+// deleteSnippet function removes an existing snippet
+func (app *Application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "toast", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// forkSnippet function copies a snippet to a new snippet owned by the current user, who can then change it
+// without affecting the original. A password protected snippet has to be unlocked before it can be forked.
+func (app *Application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.GetBySlug(chi.URLParam(r, "slug"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if app.snippetLocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+		return
+	}
+
+	// Forks expire in a year, the default on the create snippet form.
+	slug, err := app.snippets.Fork(s.ID, app.authenticatedUserID(r), "365")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "toast", "Snippet successfully forked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s", slug), http.StatusSeeOther)
+}
+
 func (app *Application) signupUserForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "signup.page.gohtml", &templateData{
 		Form: forms.New(nil),
@@ -109,7 +307,7 @@ func (app *Application) signupUser(w http.ResponseWriter, r *http.Request) {
 	// Parse the form data
 	err := r.ParseForm()
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -129,25 +327,100 @@ func (app *Application) signupUser(w http.ResponseWriter, r *http.Request) {
 
 	// Try to create a new user record in the db. If the email already exists, add an error
 	// message to the form and redisplay it.
-	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	id, err := app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.FormErrors.Add("email", "Email address is already in use")
 			app.render(w, r, "signup.page.gohtml", &templateData{Form: form})
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// The new user is inactive until they follow the verification link we email them.
+	err = app.sendVerificationEmail(id, form.Get("email"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Otherwise add a confirmation toast message to the session confirming that the signup worked
-	// and asking the user to now log in.
-	app.session.Put(r, "flash", "Your signup was successful, please log in.")
+	// and asking the user to check their email.
+	app.session.Put(r, "toast", "Your signup was successful, please check your email to verify your address.")
 
 	// And redirect to the login page.
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
+// verifyUser function activates the account of a new user, using the token from the link in the email sent
+// when they signed up
+func (app *Application) verifyUser(w http.ResponseWriter, r *http.Request) {
+	id, err := app.parseVerificationToken(r.URL.Query().Get("token"))
+	if err == nil {
+		err = app.users.Activate(id)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidVerificationToken) || errors.Is(err, models.ErrNoRecord) {
+			app.session.Put(r, "toast", "This verification link is invalid or has expired.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "toast", "Your email address has been verified, please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// resendVerificationForm function shows the form used to ask for a new email verification link
+func (app *Application) resendVerificationForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "resend.page.gohtml", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// resendVerification function emails a new verification link to the user with the given email address, if they
+// haven't verified it yet. Like forgotPassword, the same message is shown whether or not there is such a user, so
+// that the form can't be used to find out who has an account.
+func (app *Application) resendVerification(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+
+	if !form.Valid() {
+		app.render(w, r, "resend.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	id, err := app.users.UnverifiedID(form.Get("email"))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if err == nil {
+		err = app.sendVerificationEmail(id, form.Get("email"))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.session.Put(r, "toast", "If there's an unverified account for that email, we've sent it a new "+
+		"verification link.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
 func (app *Application) loginUserForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "login.page.gohtml", &templateData{
 		Form: forms.New(nil),
@@ -157,27 +430,105 @@ func (app *Application) loginUserForm(w http.ResponseWriter, r *http.Request) {
 func (app *Application) loginUser(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+
+	// A new login replaces one that was waiting for a two-factor code.
+	app.clearTwoFactorLogin(r)
+
+	// Failed logins are limited by both the client's IP address and the email address, so that neither
+	// guessing many passwords for one account nor trying one password against many accounts is quick. The
+	// attempt is counted as a failure before the password is checked, so that parallel requests can't get past
+	// the limit, and refused without checking the password if the client has to wait.
+	ipKey, emailKey := "ip:"+clientIP(r), "email:"+strings.ToLower(form.Get("email"))
+	failures, wait, err := app.loginLimiter.Attempt(ipKey, emailKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.infoLog.Printf("Login attempt for %q from %s refused, retry in %s", form.Get("email"), clientIP(r),
+			wait.Round(time.Second))
+		form.FormErrors.Add("generic", fmt.Sprintf("Too many failed login attempts, please try again in %s",
+			humanDuration(wait)))
+		app.render(w, r, "login.page.gohtml", &templateData{Form: form})
 		return
 	}
+
 	// Check if credentials are valid. If not, add a generic error message to the form failures
 	// map and re-display the login page.
-	form := forms.New(r.PostForm)
 	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.infoLog.Printf("Failed login for %q from %s (%d failures)", form.Get("email"), clientIP(r), failures)
 			form.FormErrors.Add("generic", "Email or Password is incorrect")
 			app.render(w, r, "login.page.gohtml", &templateData{Form: form})
+		} else if errors.Is(err, models.ErrUnverifiedAccount) {
+			// The password was right, so the attempt didn't fail.
+			err = app.loginLimiter.Release(ipKey, emailKey)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.FormErrors.Add("generic", "Your email address hasn't been verified yet. "+
+				"Please follow the link in the email we sent you when you signed up, or ask for a new one below.")
+			app.render(w, r, "login.page.gohtml", &templateData{Form: form})
+		} else if errors.Is(err, models.ErrInactiveAccount) {
+			err = app.loginLimiter.Release(ipKey, emailKey)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.FormErrors.Add("generic", "Your account has been deactivated.")
+			app.render(w, r, "login.page.gohtml", &templateData{Form: form})
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
+	// The earlier failures for the IP address are kept, so one account the client knows the password for can't be
+	// used to clear them.
+	err = app.loginLimiter.Release(ipKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.loginLimiter.Reset(emailKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// If the user has turned on two-factor login, the password isn't enough: remember who they are in the
+	// session and ask for a code before logging them in.
+	secret, err := app.users.TOTPSecret(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if secret != "" {
+		app.startTwoFactorLogin(w, r, id)
+		return
+	}
+
+	app.completeLogin(w, r, id)
+}
+
+// The completeLogin helper logs in the user with the given ID, once they've proved who they are, and redirects
+// them on.
+func (app *Application) completeLogin(w http.ResponseWriter, r *http.Request, id int) {
 	// Add the ID of the current user to the session, so they are now "logged in".
 	app.session.Put(r, "authenticatedUserID", id)
 
-	// Redirect the user to the create snippet page.
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	// Redirect the user to the page they were trying to reach before they were asked to log in, if any,
+	// otherwise to the home page.
+	path := app.session.PopString(r, "redirectPathAfterLogin")
+	if path == "" {
+		path = "/"
+	}
+	http.Redirect(w, r, path, http.StatusSeeOther)
 }
 
 func (app *Application) logoutUser(w http.ResponseWriter, r *http.Request) {
@@ -187,3 +538,254 @@ func (app *Application) logoutUser(w http.ResponseWriter, r *http.Request) {
 	app.session.Put(r, "toast", "You've been logged out successfully!")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// userProfile function shows the account details of the authenticated user
+func (app *Application) userProfile(w http.ResponseWriter, r *http.Request) {
+	u, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.render(w, r, "profile.page.gohtml", &templateData{
+		User: u,
+	})
+}
+
+// listTokens function shows the settings page where a user can manage their personal access tokens
+func (app *Application) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := app.tokens.List(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "tokens.page.gohtml", &templateData{
+		Form:   forms.New(nil),
+		Tokens: tokens,
+		Scopes: models.Scopes,
+	})
+}
+
+// createToken function issues a new personal access token for the authenticated user
+func (app *Application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Every scope ticked on the form has to be one of the known scopes, and at least one is needed.
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 100)
+	scopes := form.Values["scopes"]
+	if len(scopes) == 0 {
+		form.FormErrors.Add("scopes", "Choose at least one scope")
+	}
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			form.FormErrors.Add("scopes", "This field is invalid")
+			break
+		}
+	}
+
+	userID := app.authenticatedUserID(r)
+	tokens, err := app.tokens.List(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "tokens.page.gohtml", &templateData{
+			Form:   form,
+			Tokens: tokens,
+			Scopes: models.Scopes,
+		})
+		return
+	}
+
+	plaintext, err := app.tokens.Insert(userID, form.Get("name"), scopes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The plain-text token can't be retrieved again, so render it straight away rather than redirecting.
+	tokens, err = app.tokens.List(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "tokens.page.gohtml", &templateData{
+		Form:     forms.New(nil),
+		Tokens:   tokens,
+		NewToken: plaintext,
+		Scopes:   models.Scopes,
+	})
+}
+
+// revokeToken function deletes one of the authenticated user's personal access tokens
+func (app *Application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.tokens.Revoke(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "toast", "Token successfully revoked!")
+
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
+
+// changePasswordForm function shows the form for the authenticated user to change their password
+func (app *Application) changePasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "password.page.gohtml", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// changePassword function changes the authenticated user's password, after re-checking their current password
+func (app *Application) changePassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("currentPassword", "newPassword", "newPasswordConfirmation")
+	form.MinLength("newPassword", 10)
+	if form.Get("newPassword") != form.Get("newPasswordConfirmation") {
+		form.FormErrors.Add("newPasswordConfirmation", "Passwords do not match")
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "password.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	err = app.users.ChangePassword(app.authenticatedUserID(r), form.Get("currentPassword"), form.Get("newPassword"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.FormErrors.Add("currentPassword", "Current password is incorrect")
+			app.render(w, r, "password.page.gohtml", &templateData{Form: form})
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "toast", "Your password has been updated!")
+
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// forgotPasswordForm function shows the form used to request a password reset email
+func (app *Application) forgotPasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "forgot.page.gohtml", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// forgotPassword function emails a password reset link to the user with the given email address. The same
+// message is shown whether or not there is an account for the address, so that the form can't be used to find
+// out who has an account.
+func (app *Application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+
+	if !form.Valid() {
+		app.render(w, r, "forgot.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	token, err := app.users.CreatePasswordReset(form.Get("email"), passwordResetTTL)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if err == nil {
+		link := fmt.Sprintf("%s/user/password/reset?token=%s", app.baseURL, url.QueryEscape(token))
+		body := fmt.Sprintf("Someone asked to reset the password for your Snippetbox account.\n\n"+
+			"To choose a new password, open this link within the next %d minutes:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email and your password won't change.\n",
+			int(passwordResetTTL.Minutes()), link)
+		err = app.mailer.Send(form.Get("email"), "Reset your Snippetbox password", body)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.session.Put(r, "toast", "If there's an account for that email, we've sent it a password reset link.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// resetPasswordForm function shows the form used to choose a new password, for the token from the reset email
+func (app *Application) resetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "reset.page.gohtml", &templateData{
+		Form: forms.New(url.Values{"token": {r.URL.Query().Get("token")}}),
+	})
+}
+
+// resetPassword function sets a new password using a password reset token
+func (app *Application) resetPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("newPassword", "newPasswordConfirmation")
+	form.MinLength("newPassword", 10)
+	if form.Get("newPassword") != form.Get("newPasswordConfirmation") {
+		form.FormErrors.Add("newPasswordConfirmation", "Passwords do not match")
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "reset.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	err = app.users.ResetPassword(form.Get("token"), form.Get("newPassword"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			form.FormErrors.Add("generic", "This password reset link is invalid or has expired")
+			app.render(w, r, "reset.page.gohtml", &templateData{Form: form})
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "toast", "Your password has been reset, please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestShowSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Each viewer has their own server, and so their own cookie jar, on the same application.
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	aliceID := alice.login(t, app, "Alice", "alice@example.com", "pa55word1234")
+	bob := newTestServer(t, app.routes())
+	bob.login(t, app, "Bob", "bob@example.com", "pa55word1234")
+
+	insert := func(title, expires, visibility string) string {
+		slug, err := app.snippets.Insert(title, "content", models.FormatCode, "plaintext", expires, visibility, "",
+			aliceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slug
+	}
+	public := insert("Public snippet", "7", models.VisibilityPublic)
+	unlisted := insert("Unlisted snippet", "7", models.VisibilityUnlisted)
+	private := insert("Private snippet", "7", models.VisibilityPrivate)
+	// A snippet that expires after 0 days has expired as soon as it's created.
+	expired := insert("Expired snippet", "0", models.VisibilityPublic)
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Public, anonymous", anonymous, "/snippet/" + public, http.StatusOK, "Public snippet"},
+		{"Public, other user", bob, "/snippet/" + public, http.StatusOK, "Public snippet"},
+		{"Unlisted, anonymous", anonymous, "/snippet/" + unlisted, http.StatusOK, "Unlisted snippet"},
+		{"Private, owner", alice, "/snippet/" + private, http.StatusOK, "Private snippet"},
+		{"Private, other user", bob, "/snippet/" + private, http.StatusNotFound, ""},
+		{"Private, anonymous", anonymous, "/snippet/" + private, http.StatusNotFound, ""},
+		{"Expired, owner", alice, "/snippet/" + expired, http.StatusNotFound, ""},
+		{"Unknown slug", anonymous, "/snippet/AAAAAAAAAAAA", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := tt.ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+var resetLinkRX = regexp.MustCompile(`https://snippetbox\.test/user/password/reset\?token=(\S+)`)
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.users.Insert("Alice", "alice@example.com", "oldpa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Activate(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Asking for a reset for an unknown address looks the same, but no email is sent.
+	code, header, _ := ts.submitForm(t, "/user/password/forgot", "/user/password/forgot",
+		url.Values{"email": {"nobody@example.com"}})
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Fatalf("unknown email: want %d to /user/login; got %d to %q", http.StatusSeeOther, code,
+			header.Get("Location"))
+	}
+	if mails := readMails(t, app); len(mails) != 0 {
+		t.Fatalf("unknown email: want no emails; got %d", len(mails))
+	}
+
+	code, header, _ = ts.submitForm(t, "/user/password/forgot", "/user/password/forgot",
+		url.Values{"email": {"alice@example.com"}})
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Fatalf("forgot: want %d to /user/login; got %d to %q", http.StatusSeeOther, code, header.Get("Location"))
+	}
+	mails := readMails(t, app)
+	if len(mails) != 1 {
+		t.Fatalf("forgot: want 1 email; got %d", len(mails))
+	}
+	if !strings.Contains(mails[0], "To: alice@example.com") {
+		t.Errorf("forgot: want email to alice@example.com; got %q", mails[0])
+	}
+	matches := resetLinkRX.FindStringSubmatch(mails[0])
+	if matches == nil {
+		t.Fatalf("forgot: no reset link in %q", mails[0])
+	}
+	token, err := url.QueryUnescape(matches[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired token is made directly in the store, as the handler always uses passwordResetTTL.
+	expiredToken, err := app.users.CreatePasswordReset("alice@example.com", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reset := func(token, password string) (int, http.Header, string) {
+		return ts.submitForm(t, "/user/password/reset?token="+url.QueryEscape(token), "/user/password/reset",
+			url.Values{"token": {token}, "newPassword": {password}, "newPasswordConfirmation": {password}})
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{"Expired token", expiredToken, "newpa55word1", http.StatusOK, "", "invalid or has expired"},
+		{"Unknown token", "NOTAREALTOKEN", "newpa55word1", http.StatusOK, "", "invalid or has expired"},
+		{"Short password", token, "short", http.StatusOK, "", "This field is too short"},
+		{"Valid token", token, "newpa55word1", http.StatusSeeOther, "/user/login", ""},
+		{"Used token", token, "otherpa55word", http.StatusOK, "", "invalid or has expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := reset(tt.token, tt.password)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if header.Get("Location") != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, header.Get("Location"))
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Only the password set with the valid token works afterwards.
+	_, err = app.users.Authenticate("alice@example.com", "oldpa55word1")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("old password: want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+	_, err = app.users.Authenticate("alice@example.com", "otherpa55word")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("password from used token: want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+	_, err = app.users.Authenticate("alice@example.com", "newpa55word1")
+	if err != nil {
+		t.Errorf("new password: want no error; got %v", err)
+	}
+}
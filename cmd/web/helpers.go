@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/justinas/nosurf"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"time"
 )
 
@@ -17,6 +22,14 @@ func (app *Application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.CurrentYear = time.Now().Year()
 	td.Toast = app.session.PopString(r, "toast")
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.AuthenticatedUserID = app.authenticatedUserID(r)
+	td.CSRFToken = nosurf.Token(r)
+	td.OIDCEnabled = app.oidc != nil
+	td.IsModerator = models.HasRole(app.authenticatedUserRole(r), models.RoleModerator)
+	td.IsAdmin = models.HasRole(app.authenticatedUserRole(r), models.RoleAdmin)
+	td.Formats = models.Formats
+	td.Languages = models.Languages
+	td.BaseURL = app.baseURL
 	return td
 }
 
@@ -26,7 +39,7 @@ func (app *Application) render(w http.ResponseWriter, r *http.Request, name stri
 	// in the cache with the provided name, call the serverError helper method.
 	ts, ok := app.templateCache[name]
 	if !ok {
-		app.serverError(w, fmt.Errorf("the template %s does not exist", name))
+		app.serverError(w, r, fmt.Errorf("the template %s does not exist", name))
 		return
 	}
 	// Initialize a new buffer
@@ -36,7 +49,7 @@ func (app *Application) render(w http.ResponseWriter, r *http.Request, name stri
 	// If there is an error, call serverError (500 error)
 	err := ts.Execute(buf, app.addDefaultData(td, r))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -46,25 +59,92 @@ func (app *Application) render(w http.ResponseWriter, r *http.Request, name stri
 
 // The serverError helper writes an error message and stack trace to the errorLog, then sends
 // a generic 500 Internal Server Error response to the server
-func (app *Application) serverError(w http.ResponseWriter, err error) {
+func (app *Application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.clientError(w, r, http.StatusInternalServerError)
 }
 
 // The clientError helper sends a specific status code and corresponding description to the user.
 // This can be used to send responses like 400 "Bad Request" when there is a problem with the request the user sent.
-func (app *Application) clientError(w http.ResponseWriter, status int) {
+// Requests to the JSON API get the description as a JSON error body rather than plain text.
+func (app *Application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	if isAPIRequest(r) {
+		app.writeJSON(w, status, apiError{Error: http.StatusText(status)})
+		return
+	}
 	http.Error(w, http.StatusText(status), status)
 }
 
 // For consistency, implement a notFound helper. This is a convenience wrapper around clientError which
 // sends a 404 Not Found response to the user.
-func (app *Application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+func (app *Application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// Return true if the request is for one of the JSON API routes.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// The writeJSON helper encodes data as JSON and sends it with the given status code. The data is encoded to a
+// buffer first so that an encoding error can still be reported as a 500.
+func (app *Application) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	buf := new(bytes.Buffer)
+	err := json.NewEncoder(buf).Encode(data)
+	if err != nil {
+		trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+		app.errorLog.Output(2, trace)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// The clientIP helper returns the IP address of the client that made the request, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// The humanDuration helper formats a wait for a user to read, rounded up to whole seconds or minutes, e.g.
+// "1 minute" or "30 seconds".
+func humanDuration(d time.Duration) string {
+	n, unit := int((d+time.Second-1)/time.Second), "second"
+	if d > time.Minute {
+		n, unit = int((d+time.Minute-1)/time.Minute), "minute"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
 }
 
 // Return true if the current request is from an authenticated user, otherwise return false.
 func (app *Application) isAuthenticated(r *http.Request) bool {
-	return app.session.Exists(r, "authenticatedUserID")
+	return app.authenticatedUserID(r) != 0
+}
+
+// Return the ID of the currently authenticated user, or 0 if the request is not from an authenticated user. API
+// requests authenticated with a personal access token use the user the token belongs to, otherwise the user ID
+// confirmed by the authenticate middleware is used.
+func (app *Application) authenticatedUserID(r *http.Request) int {
+	if t, ok := r.Context().Value(contextKeyToken).(*models.Token); ok {
+		return t.UserID
+	}
+	id, _ := r.Context().Value(contextKeyAuthenticatedUserID).(int)
+	return id
+}
+
+// Return the role of the user logged in with the session, or an empty string if there isn't one. Requests
+// authenticated with a personal access token don't have a role, so they can't use the admin pages.
+func (app *Application) authenticatedUserRole(r *http.Request) string {
+	role, _ := r.Context().Value(contextKeyAuthenticatedUserRole).(string)
+	return role
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Snippets are highlighted with inline styles, so that no extra stylesheet is needed wherever they're shown.
+var (
+	highlightFormatter = html.New(html.WithClasses(false), html.TabWidth(4))
+	highlightStyle     = styles.Get("github")
+)
+
+// shebangRX matches the interpreter named in a script's first line, e.g. "#!/usr/bin/env python3".
+var shebangRX = regexp.MustCompile(`^#!(?:\S*/env\s+|\S*/)(\w+)`)
+
+// The shebangLanguages map gives the language of a script from the interpreter in its shebang line.
+var shebangLanguages = map[string]string{
+	"sh":      "bash",
+	"bash":    "bash",
+	"zsh":     "bash",
+	"python":  "python",
+	"python3": "python",
+	"ruby":    "ruby",
+	"node":    "javascript",
+	"php":     "php",
+}
+
+// The detectLanguage function guesses the language of a snippet's content, for when the author doesn't choose
+// one. Scripts are recognised by their shebang line and JSON by parsing it, and anything else is left to the
+// highlighter's own analysis. Content which can't be recognised as one of models.Languages is plain text.
+func detectLanguage(content string) string {
+	content = strings.TrimSpace(content)
+
+	if m := shebangRX.FindStringSubmatch(content); m != nil {
+		if language, ok := shebangLanguages[m[1]]; ok {
+			return language
+		}
+	}
+	if (strings.HasPrefix(content, "{") || strings.HasPrefix(content, "[")) && json.Valid([]byte(content)) {
+		return "json"
+	}
+
+	if lexer := lexers.Analyse(content); lexer != nil {
+		for _, language := range models.Languages {
+			if lexers.Get(language) == lexer {
+				return language
+			}
+		}
+	}
+	if strings.HasPrefix(content, "<") && strings.HasSuffix(content, ">") {
+		if strings.HasPrefix(content, "<?xml") {
+			return "xml"
+		}
+		return "html"
+	}
+	return models.LanguagePlainText
+}
+
+// The highlight function formats a snippet's content as HTML with syntax highlighting for its language. It's
+// used in the templates as {{highlight .Content .Language}}. If the content can't be highlighted, it's shown as
+// plain preformatted text instead.
+func highlight(content, language string) template.HTML {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// Coalesce runs of tokens of the same type, which makes the HTML smaller.
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err == nil {
+		buf := new(bytes.Buffer)
+		err = highlightFormatter.Format(buf, highlightStyle, iterator)
+		if err == nil {
+			return template.HTML(buf.String())
+		}
+	}
+	return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+}
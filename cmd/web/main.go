@@ -8,12 +8,20 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golangcollege/sessions"
 	"github.com/joho/godotenv"
+	"github.com/rlr524/snippetbox/pkg/limiter"
+	"github.com/rlr524/snippetbox/pkg/mailer"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"github.com/rlr524/snippetbox/pkg/models/memory"
 	"github.com/rlr524/snippetbox/pkg/models/mysql"
+	"github.com/rlr524/snippetbox/pkg/models/postgres"
+	"github.com/rlr524/snippetbox/pkg/models/sqlite"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,9 +29,15 @@ type Application struct {
 	errorLog      *log.Logger
 	infoLog       *log.Logger
 	session       *sessions.Session
-	snippets      *mysql.SnippetModel //Points to the SnippetModel struct that wraps the DB connection pool
-	users         *mysql.UserModel    //UserModel points to the UserModel struct that wraps the DB connection pool
+	snippets      models.SnippetStore // Any storage backend's snippet model, e.g. mysql.SnippetModel
+	users         models.UserStore    // Any storage backend's user model, e.g. mysql.UserModel
+	tokens        models.TokenStore   // Any storage backend's token model, e.g. mysql.TokenModel
 	templateCache map[string]*template.Template
+	mailer        mailer.Mailer    // Sends emails, such as password reset links
+	baseURL       string           // Used to build absolute links in emails and embed codes
+	secret        []byte           // Signs email verification links
+	loginLimiter  *limiter.Limiter // Slows down and locks out repeated failed logins
+	oidc          *oidcProvider    // Single sign-on identity provider, nil if it isn't configured
 }
 
 func main() {
@@ -36,45 +50,120 @@ func main() {
 	sessionSecret := os.Getenv("SESSION_SECRET")
 	// Command line flag for the port
 	addr := flag.String("addr", ":4000", "HTTP network address")
-	// Command line flag for the MySQL DSN currently located on local Docker container
+	// Command line flags for the storage backend and its DSN. If no DSN is given, the default for the chosen
+	// driver is used; for MySQL that's the database currently located on local Docker container.
 	// TODO: Encrypt the password
-	dsn := flag.String("dsn", fmt.Sprintf("web:%s@tcp(lancer:3306)/snippetbox?parseTime=true", dbPass),
-		"MySQL data source name")
+	driver := flag.String("db-driver", "mysql", "Database driver (mysql, postgres, sqlite or memory)")
+	dsn := flag.String("dsn", "", "Data source name (defaults to one for the chosen -db-driver)")
 	secret := flag.String("secret", sessionSecret, "Secret key")
+	// Command line flag to apply any pending migrations before the server starts
+	migrateOnStart := flag.Bool("migrate", false, "Apply pending database migrations on startup")
+	// Command line flags for sending email. Without an SMTP server, emails are written to files in -mail-dir.
+	baseURL := flag.String("base-url", "https://localhost:4000", "Base URL used for absolute links, e.g. in emails")
+	smtpAddr := flag.String("smtp-addr", "", "SMTP server address (host:port)")
+	smtpUsername := flag.String("smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP username")
+	mailFrom := flag.String("mail-from", "Snippetbox <no-reply@snippetbox.local>", "Sender address for emails")
+	mailDir := flag.String("mail-dir", "./tmp/mail", "Directory emails are written to when -smtp-addr isn't set")
+	// Command line flags for single sign-on through an OpenID Connect provider, which is off unless -oidc-issuer
+	// is set. The client secret is read from the OIDC_CLIENT_SECRET environment variable.
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL for single sign-on")
+	oidcClientID := flag.String("oidc-client-id", "snippetbox", "OpenID Connect client ID")
 	flag.Parse()
 
+	defaultDSNs := map[string]string{
+		"mysql":    fmt.Sprintf("web:%s@tcp(lancer:3306)/snippetbox?parseTime=true", dbPass),
+		"postgres": fmt.Sprintf("postgres://web:%s@lancer:5432/snippetbox?sslmode=disable", dbPass),
+		"sqlite":   "file:snippetbox.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+	}
+	if *dsn == "" {
+		*dsn = defaultDSNs[*driver]
+	}
+
 	infoLog := log.New(os.Stdout, "INFO:\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR:\t", log.Ldate|log.Ltime|log.Llongfile)
 
-	db, err := openDB(*dsn)
+	// Initialize an instance of Application containing logging dependencies
+	app := &Application{
+		errorLog: errorLog,
+		infoLog:  infoLog,
+	}
+
+	// Open the database and add the models for the chosen storage backend to the Application
+	db, err := app.openStorage(*driver, *dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
-	// Close the db connection pool before the main() function exits
-	defer func(db *sql.DB) {
-		err := db.Close()
+	// Close the db connection pool before the main() function exits. The memory backend has no connection pool.
+	if db != nil {
+		defer func(db *sql.DB) {
+			err := db.Close()
+			if err != nil {
+				errorLog.Fatal()
+			}
+		}(db)
+	}
+
+	// Run the "migrate up|down|status|baseline" subcommand instead of the server if it was given after the flags.
+	if flag.Arg(0) == "migrate" {
+		err = app.migrate(db, *driver, flag.Args()[1:]...)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+	// Run the "set-role <user-id> <role>" subcommand, which is how the first admin is made.
+	if flag.Arg(0) == "set-role" {
+		id, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || !models.ValidRole(flag.Arg(2)) {
+			errorLog.Fatalf("usage: web [flags] set-role <user-id> <%s>", strings.Join(models.Roles, "|"))
+		}
+		err = app.users.SetRole(id, flag.Arg(2))
 		if err != nil {
-			errorLog.Fatal()
+			errorLog.Fatal(err)
 		}
-	}(db)
+		infoLog.Printf("User %d is now a %s", id, flag.Arg(2))
+		return
+	}
+	if *migrateOnStart && db != nil {
+		err = app.migrate(db, *driver, "up")
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
 
-	// Initialize a new template cache.
+	// Initialize a new template cache and add it to the Application along with the session manager
 	templateCache, err := newTemplateCache("./ui/html/")
 	if err != nil {
 		errorLog.Fatal(err)
 	}
+	app.templateCache = templateCache
 
-	session := sessions.New([]byte(*secret))
-	session.Lifetime = 12 * time.Hour
+	// Failed logins are counted in memory, which is enough for a single server. The counts are kept for as long
+	// as the limiter's lockout.
+	app.loginLimiter = limiter.New(limiter.NewMemoryStore(15 * time.Minute))
 
-	// Initialize an instance of Application containing logging dependencies, models and cache
-	app := &Application{
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		snippets:      &mysql.SnippetModel{DB: db},
-		users:         &mysql.UserModel{DB: db},
-		templateCache: templateCache,
+	app.secret = []byte(*secret)
+	app.session = sessions.New(app.secret)
+	app.session.Lifetime = 12 * time.Hour
+
+	app.baseURL = strings.TrimSuffix(*baseURL, "/")
+	if *smtpAddr != "" {
+		app.mailer = &mailer.SMTPMailer{
+			Addr:     *smtpAddr,
+			Username: *smtpUsername,
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     *mailFrom,
+		}
+	} else {
+		app.mailer = &mailer.FileMailer{Dir: *mailDir, From: *mailFrom}
+	}
+
+	if *oidcIssuer != "" {
+		app.oidc, err = newOIDCProvider(http.DefaultClient, *oidcIssuer, *oidcClientID,
+			os.Getenv("OIDC_CLIENT_SECRET"), app.baseURL+"/user/login/oidc/callback")
+		if err != nil {
+			errorLog.Fatal(err)
+		}
 	}
 
 	// Struct to hold non-default TLS settings; only changing the curve preferences value so that only elliptic
@@ -99,9 +188,52 @@ func main() {
 	errorLog.Fatal(err)
 }
 
-// The openDB function returns a pool of connections for the MySQL DB
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// The openStorage method opens the database for the named driver and sets the Application's models to the ones
+// from the matching storage backend. It returns the connection pool so that it can be closed, which is nil for the
+// memory backend.
+func (app *Application) openStorage(driver, dsn string) (*sql.DB, error) {
+	switch driver {
+	case "mysql":
+		db, err := openDB("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		app.snippets = &mysql.SnippetModel{DB: db}
+		app.users = &mysql.UserModel{DB: db}
+		app.tokens = &mysql.TokenModel{DB: db}
+		return db, nil
+	case "postgres":
+		db, err := openDB("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		app.snippets = &postgres.SnippetModel{DB: db}
+		app.users = &postgres.UserModel{DB: db}
+		app.tokens = &postgres.TokenModel{DB: db}
+		return db, nil
+	case "sqlite":
+		db, err := openDB("sqlite", dsn)
+		if err != nil {
+			return nil, err
+		}
+		app.snippets = &sqlite.SnippetModel{DB: db}
+		app.users = &sqlite.UserModel{DB: db}
+		app.tokens = &sqlite.TokenModel{DB: db}
+		return db, nil
+	case "memory":
+		db := memory.NewDB()
+		app.snippets = &memory.SnippetModel{DB: db}
+		app.users = &memory.UserModel{DB: db}
+		app.tokens = &memory.TokenModel{DB: db}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// The openDB function returns a pool of connections for the DB
+func openDB(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, err
 	}
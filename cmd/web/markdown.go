@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"html/template"
+	"sync"
+)
+
+// Markdown is rendered with GitHub flavoured extensions. Goldmark's safe default mode is kept, which leaves out
+// raw HTML and links with dangerous URLs, and the result is sanitized again in case anything gets past it.
+var (
+	markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	markdownPolicy   = bluemonday.UGCPolicy()
+)
+
+// maxMarkdownCacheEntries limits the number of rendered snippets kept in the markdown cache.
+const maxMarkdownCacheEntries = 1000
+
+// The markdownCache type holds rendered markdown keyed by a hash of the source, so each revision of a snippet is
+// only rendered once. Editing a snippet changes the hash, so there's nothing to invalidate. When the cache is full
+// it's emptied, which keeps it simple and bounded at the cost of rendering the popular snippets again.
+type markdownCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]template.HTML
+}
+
+var renderedMarkdown = &markdownCache{entries: map[[sha256.Size]byte]template.HTML{}}
+
+// The markdown function renders a snippet's markdown content as sanitized HTML. It's used in the templates as
+// {{markdown .Content}}. If the content can't be rendered, it's shown as plain preformatted text instead.
+func markdown(content string) template.HTML {
+	key := sha256.Sum256([]byte(content))
+
+	renderedMarkdown.mu.Lock()
+	html, ok := renderedMarkdown.entries[key]
+	renderedMarkdown.mu.Unlock()
+	if ok {
+		return html
+	}
+
+	buf := new(bytes.Buffer)
+	err := markdownRenderer.Convert([]byte(content), buf)
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+	html = template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes()))
+
+	renderedMarkdown.mu.Lock()
+	if len(renderedMarkdown.entries) >= maxMarkdownCacheEntries {
+		renderedMarkdown.entries = map[[sha256.Size]byte]template.HTML{}
+	}
+	renderedMarkdown.entries[key] = html
+	renderedMarkdown.mu.Unlock()
+	return html
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantNot string
+	}{
+		{"Heading", "# Title", "<h1>Title</h1>", ""},
+		{"Table", "| a |\n|---|\n| b |", "<td>b</td>", ""},
+		{"Script tag", "<script>alert(1)</script>", "", "<script"},
+		{"Inline event handler", `<img src="x.png" onerror="alert(1)">`, "", "onerror"},
+		{"JavaScript link", "[click](javascript:alert(1))", "click", "javascript:"},
+		{"Autolinked JavaScript", "<javascript:alert(1)>", "", "href=\"javascript:"},
+		{"Safe link", "[docs](https://example.com/)", `href="https://example.com/"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := string(markdown(tt.content))
+
+			if !strings.Contains(html, tt.want) {
+				t.Errorf("want %q in %q", tt.want, html)
+			}
+			if tt.wantNot != "" && strings.Contains(html, tt.wantNot) {
+				t.Errorf("don't want %q in %q", tt.wantNot, html)
+			}
+		})
+	}
+}
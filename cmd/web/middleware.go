@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/justinas/nosurf"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"mime"
 	"net/http"
+	"strings"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -34,7 +41,7 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 				w.Header().Set("Connection", "close")
 
 				// Call the app.ServerError helper to return a 500 response.
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -42,11 +49,48 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// The authenticate middleware checks that the user ID stored in the session by loginUser still refers to an
+// active user, and if so adds it to the request context. A user who has been deleted or deactivated since they
+// logged in is removed from the session, so they're no longer treated as logged in.
+func (app *Application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.session.GetInt(r, "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		if u == nil || !u.Active {
+			app.session.Remove(r, "authenticatedUserID")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyAuthenticatedUserID, id)
+		ctx = context.WithValue(ctx, contextKeyAuthenticatedUserRole, u.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If the user is not authenticated, redirect them to the login page and return from the middleware
-		// chain so that no subsequent handlers in the chain are executed.
+		// chain so that no subsequent handlers in the chain are executed. For GET requests, remember the URL
+		// they were trying to reach so that loginUser can send them back there once they've logged in.
+		// API clients get a 401 Unauthorized instead, as there is no login page for them to be sent to.
 		if !app.isAuthenticated(r) {
+			if isAPIRequest(r) {
+				app.clientError(w, r, http.StatusUnauthorized)
+				return
+			}
+			if r.Method == http.MethodGet {
+				app.session.Put(r, "redirectPathAfterLogin", r.URL.RequestURI())
+			}
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
@@ -59,6 +103,135 @@ func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// The noSurf middleware uses a customized CSRF cookie with the Secure, Path and HttpOnly flags set. Any
+// state-changing request without a valid CSRF token is rejected with a 400 Bad Request.
+func (app *Application) noSurf(next http.Handler) http.Handler {
+	csrfHandler := nosurf.New(next)
+	csrfHandler.SetBaseCookie(http.Cookie{
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.clientError(w, r, http.StatusBadRequest)
+	}))
+	return csrfHandler
+}
+
+// The requireJSON middleware rejects API requests with a body that isn't declared as JSON. As well as telling the
+// client what went wrong, this protects the session-authenticated API from cross-site form submissions, since a
+// browser won't send a cross-origin application/json request without a CORS preflight.
+func (app *Application) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				app.clientError(w, r, http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticateToken middleware authenticates API requests that carry a personal access token in an
+// "Authorization: Bearer <token>" header. The token is added to the request context, which makes its user the
+// authenticated user for the rest of the request. Requests without an Authorization header are passed through
+// unchanged, so the session can still be used.
+func (app *Application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		plaintext := strings.TrimPrefix(header, "Bearer ")
+		if plaintext == header || plaintext == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.clientError(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		t, err := app.tokens.Authenticate(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.clientError(w, r, http.StatusUnauthorized)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyToken, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The requireScope middleware checks that a request authenticated with a personal access token has been granted
+// the given scope, and sends a 403 Forbidden if not. Requests authenticated with the session aren't limited by
+// scopes.
+func (app *Application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if t, ok := r.Context().Value(contextKeyToken).(*models.Token); ok && !t.HasScope(scope) {
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The requireRole middleware only lets through users with at least the given role (see models.HasRole), any
+// other user gets a 403 Forbidden. It's used after requireAuthentication, so the user is already logged in.
+func (app *Application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !models.HasRole(app.authenticatedUserRole(r), role) {
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			// Pages which depend on the user's role shouldn't be stored in the browser's cache either.
+			w.Header().Add("Cache-Control", "no-store")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *Application) requireSnippetOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retrieve the snippet identified by the slug URL parameter. If it doesn't exist (or has expired, or is
+		// someone else's private snippet) send a 404.
+		s, err := app.snippets.GetBySlug(chi.URLParam(r, "slug"), app.authenticatedUserID(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		// Only the author of the snippet may change it, any other user gets a 403 Forbidden. This middleware is
+		// used after requireAuthentication, so the user is already known to be logged in.
+		if s.UserID != app.authenticatedUserID(r) {
+			app.clientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		// Add the snippet to the request context so that the next handler doesn't need to fetch it again.
+		ctx := context.WithValue(r.Context(), contextKeySnippet, s)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // This is a more common and simplified version of the secureHeaders function (and applicable to the other
 // middleware functions as well). In my opinion, the above is more readable and makes it more clear that
 // the fn function is a closure over the next handler.
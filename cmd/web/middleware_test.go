@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestNoSurf(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// Visiting a page first sets the CSRF cookie, so only the token in the form is missing or wrong.
+	ts.get(t, "/user/login")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"No token", ""},
+		{"Wrong token", "d3JvbmctdG9rZW4td3JvbmctdG9rZW4td3JvbmctdG9rZW4td3Jvbmc="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"email": {"alice@example.com"}, "password": {"pa55word1234"}}
+			if tt.token != "" {
+				form.Set("csrf_token", tt.token)
+			}
+
+			code, _, _ := ts.postForm(t, "/user/login", form)
+			if code != http.StatusBadRequest {
+				t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+			}
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Each user has their own server, and so their own cookie jar, on the same application.
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	aliceID := alice.login(t, app, "Alice", "alice@example.com", "pa55word1234")
+	bob := newTestServer(t, app.routes())
+	bob.login(t, app, "Bob", "bob@example.com", "pa55word1234")
+	moderator := newTestServer(t, app.routes())
+	moderatorID := moderator.login(t, app, "Mo", "mo@example.com", "pa55word1234")
+	admin := newTestServer(t, app.routes())
+	adminID := admin.login(t, app, "Ada", "ada@example.com", "pa55word1234")
+
+	// The role is looked up on every request, so it can be changed after logging in.
+	err := app.users.SetRole(moderatorID, models.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.SetRole(adminID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slug, err := app.snippets.Insert("Alice's snippet", "content", models.FormatCode, "plaintext", "7",
+		models.VisibilityPublic, "", aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// POST requests are made from the snippet creation page, which has a CSRF token.
+	tests := []struct {
+		name         string
+		ts           *testServer
+		method       string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Edit, owner", alice, http.MethodGet, "/snippet/" + slug + "/edit", http.StatusOK, ""},
+		{"Edit, other user", bob, http.MethodGet, "/snippet/" + slug + "/edit", http.StatusForbidden, ""},
+		{"Edit, moderator", moderator, http.MethodGet, "/snippet/" + slug + "/edit", http.StatusForbidden, ""},
+		{"Edit, anonymous", anonymous, http.MethodGet, "/snippet/" + slug + "/edit", http.StatusSeeOther,
+			"/user/login"},
+		{"Delete, other user", bob, http.MethodPost, "/snippet/" + slug + "/delete", http.StatusForbidden, ""},
+		{"Restore, other user", bob, http.MethodPost, "/snippet/" + slug + "/revisions/1/restore",
+			http.StatusForbidden, ""},
+		{"Admin, user", bob, http.MethodGet, "/admin", http.StatusForbidden, ""},
+		{"Admin, moderator", moderator, http.MethodGet, "/admin", http.StatusForbidden, ""},
+		{"Admin, admin", admin, http.MethodGet, "/admin", http.StatusOK, ""},
+		{"Admin, anonymous", anonymous, http.MethodGet, "/admin", http.StatusSeeOther, "/user/login"},
+		{"Remove snippet, user", bob, http.MethodPost, "/admin/snippets/" + slug + "/delete",
+			http.StatusForbidden, ""},
+		{"Remove snippet, moderator", moderator, http.MethodPost, "/admin/snippets/" + slug + "/delete",
+			http.StatusSeeOther, "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var header http.Header
+			if tt.method == http.MethodPost {
+				code, header, _ = tt.ts.submitForm(t, "/snippet/create", tt.urlPath, url.Values{})
+			} else {
+				code, header, _ = tt.ts.get(t, tt.urlPath)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if header.Get("Location") != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, header.Get("Location"))
+			}
+		})
+	}
+
+	// Only the moderator's request removed the snippet.
+	_, err = app.snippets.GetBySlug(slug, aliceID)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want error %v; got %v", models.ErrNoRecord, err)
+	}
+}
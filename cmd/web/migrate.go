@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/rlr524/snippetbox/pkg/migrate"
+	"github.com/rlr524/snippetbox/pkg/models/mysql"
+	"github.com/rlr524/snippetbox/pkg/models/postgres"
+	"github.com/rlr524/snippetbox/pkg/models/sqlite"
+	"io/fs"
+	"strconv"
+)
+
+// The migrations map holds the embedded migrations for each storage backend which uses a database. The memory
+// backend has no schema, so it has no migrations.
+var migrations = map[string]fs.FS{
+	"mysql":    mysql.Migrations,
+	"postgres": postgres.Migrations,
+	"sqlite":   sqlite.Migrations,
+}
+
+// The migrate method runs the "migrate" subcommand, e.g. "web -db-driver=sqlite migrate up". The command is one
+// of up (apply all pending migrations), down (roll back the most recent migration), status (list the
+// migrations and whether they have been applied) or "baseline N" (record migrations 1 to N as applied without
+// running them, for a database whose schema was created by hand).
+func (app *Application) migrate(db *sql.DB, driver string, args ...string) error {
+	var command string
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	fsys, ok := migrations[driver]
+	if !ok {
+		return fmt.Errorf("the %s storage backend has no migrations", driver)
+	}
+	m, err := migrate.New(db, fsys)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			app.infoLog.Printf("Applied migration %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			app.infoLog.Print("No pending migrations")
+		}
+	case "down":
+		mig, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if mig == nil {
+			app.infoLog.Print("No migrations to roll back")
+		} else {
+			app.infoLog.Printf("Rolled back migration %04d_%s", mig.Version, mig.Name)
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			app.infoLog.Printf("%04d_%s\t%s", s.Version, s.Name, state)
+		}
+	case "baseline":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate baseline <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid baseline version %q", args[1])
+		}
+		done, err := m.Baseline(version)
+		if err != nil {
+			return err
+		}
+		for _, mig := range done {
+			app.infoLog.Printf("Recorded migration %04d_%s as applied", mig.Version, mig.Name)
+		}
+		if len(done) == 0 {
+			app.infoLog.Printf("Migrations up to %04d were already applied", version)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down, status or baseline", command)
+	}
+	return nil
+}
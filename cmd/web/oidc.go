@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"golang.org/x/oauth2"
+)
+
+// The oidcProvider type holds what's needed to sign users in through an OpenID Connect identity provider, such as
+// the company's single sign-on service.
+type oidcProvider struct {
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	client   *http.Client // Used for every request to the provider
+}
+
+// The newOIDCProvider function fetches the provider's configuration from its discovery document at
+// issuer/.well-known/openid-configuration. Requests to the provider are made with client, so a fake provider
+// (e.g. an httptest.Server with its own certificate) can be used in tests.
+func newOIDCProvider(client *http.Client, issuer, clientID, clientSecret, redirectURL string) (*oidcProvider, error) {
+	// The provider keeps the context to fetch its signing keys later, so it mustn't be one which is cancelled.
+	ctx := oidc.ClientContext(context.Background(), client)
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcProvider{
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		client:   client,
+	}, nil
+}
+
+// The randomString function returns n random bytes from the operating system's CSPRNG, base64url encoded, for
+// the state, nonce and PKCE code verifier.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// oidcLogin function starts signing in with the identity provider. It redirects to the provider's authorization
+// endpoint, after keeping the state, nonce and PKCE code verifier in the session for oidcCallback to check.
+func (app *Application) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	// A new login replaces one that was waiting for a two-factor code.
+	app.clearTwoFactorLogin(r)
+
+	var values [3]string
+	for i := range values {
+		s, err := randomString(32)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		values[i] = s
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	app.session.Put(r, "oidcState", state)
+	app.session.Put(r, "oidcNonce", nonce)
+	app.session.Put(r, "oidcVerifier", verifier)
+
+	challenge := sha256.Sum256([]byte(verifier))
+	url := app.oidc.config.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// oidcCallback function finishes signing in with the identity provider. It checks the state, exchanges the
+// authorization code (with the PKCE code verifier) for an ID token, verifies the token and its nonce, then logs in
+// the user with the token's verified email address.
+func (app *Application) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	// Each value can only be used once, whether or not signing in works.
+	state := app.session.PopString(r, "oidcState")
+	nonce := app.session.PopString(r, "oidcNonce")
+	verifier := app.session.PopString(r, "oidcVerifier")
+
+	q := r.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		app.oidcFailed(w, r, "Single sign-on was cancelled or refused.", errors.New(e))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, app.oidc.client)
+	token, err := app.oidc.config.Exchange(ctx, q.Get("code"), oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		app.oidcFailed(w, r, "Single sign-on failed, please try again.", err)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		app.oidcFailed(w, r, "Single sign-on failed, please try again.", errors.New("no id_token in token response"))
+		return
+	}
+	idToken, err := app.oidc.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		app.oidcFailed(w, r, "Single sign-on failed, please try again.", err)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		app.oidcFailed(w, r, "Single sign-on failed, please try again.", errors.New("id_token nonce mismatch"))
+		return
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		app.oidcFailed(w, r, "Single sign-on failed, please try again.", err)
+		return
+	}
+	// Accounts are linked by email address, so it must be one the provider has checked belongs to the user.
+	if claims.Email == "" || !claims.EmailVerified {
+		app.oidcFailed(w, r, "Your identity provider hasn't verified your email address.",
+			errors.New("email not verified"))
+		return
+	}
+	if claims.Name == "" {
+		claims.Name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	id, err := app.users.AuthenticateOIDC(idToken.Issuer, idToken.Subject, claims.Name, claims.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrInactiveAccount) {
+			app.oidcFailed(w, r, "Your account isn't active.", err)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Users who have turned on two-factor login are asked for a code, in the same way as after a password.
+	secret, err := app.users.TOTPSecret(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if secret != "" {
+		app.startTwoFactorLogin(w, r, id)
+		return
+	}
+
+	app.completeLogin(w, r, id)
+}
+
+// The oidcFailed helper logs why signing in with the identity provider failed and sends the user back to the
+// login page with a message.
+func (app *Application) oidcFailed(w http.ResponseWriter, r *http.Request, message string, err error) {
+	app.infoLog.Printf("Single sign-on from %s failed: %v", clientIP(r), err)
+	app.session.Put(r, "toast", message)
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// fakeIssuer is an OpenID Connect identity provider for tests. It approves every authorization request straight
+// away, and signs ID tokens with the claims set in its fields.
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// The claims put in the next ID token. If wrongNonce is set, the token has a different nonce from the one in
+	// the authorization request.
+	subject       string
+	email         string
+	emailVerified bool
+	name          string
+	wrongNonce    bool
+
+	// The nonce and PKCE code challenge from the latest authorization request.
+	nonce     string
+	challenge string
+}
+
+// The newFakeIssuer function starts a fakeIssuer on a TLS test server, which newOIDCProvider can use through the
+// server's own client.
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("/keys", f.keys)
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	f.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	f.reply(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                f.URL,
+		"authorization_endpoint":                f.URL + "/authorize",
+		"token_endpoint":                        f.URL + "/token",
+		"jwks_uri":                              f.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (f *fakeIssuer) keys(w http.ResponseWriter, r *http.Request) {
+	f.reply(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &f.key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+}
+
+// The authorize method remembers the nonce and code challenge, then sends the user straight back to the
+// application with an authorization code.
+func (f *fakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f.nonce = q.Get("nonce")
+	f.challenge = q.Get("code_challenge")
+
+	callback := q.Get("redirect_uri") + "?" + url.Values{"code": {"test-code"}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, callback, http.StatusFound)
+}
+
+// The token method exchanges the authorization code for a signed ID token, once the PKCE code verifier matches the
+// challenge from the authorization request.
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		f.reply(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("code") != "test-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+		f.reply(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := f.nonce
+	if f.wrongNonce {
+		nonce = "wrong-nonce"
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":            f.URL,
+		"sub":            f.subject,
+		"aud":            "snippetbox",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          f.email,
+		"email_verified": f.emailVerified,
+		"name":           f.name,
+	})
+	if err != nil {
+		f.reply(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: f.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		f.reply(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		f.reply(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	idToken, err := signed.CompactSerialize()
+	if err != nil {
+		f.reply(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	f.reply(w, http.StatusOK, map[string]interface{}{
+		"access_token": "test-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// The reply method sends a JSON response, as the provider's endpoints do
+func (f *fakeIssuer) reply(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// The newOIDCTestServer function starts the application with single sign-on through the fake issuer
+func newOIDCTestServer(t *testing.T, app *Application, issuer *fakeIssuer) *testServer {
+	t.Helper()
+
+	provider, err := newOIDCProvider(issuer.Client(), issuer.URL, "snippetbox", "test-secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.oidc = provider
+
+	// The redirect URL can only be set once the server has started and its URL is known.
+	ts := newTestServer(t, app.routes())
+	provider.config.RedirectURL = ts.URL + "/user/login/oidc/callback"
+	return ts
+}
+
+// The authorizeOIDC function starts single sign-on and follows the redirect to the fake issuer, returning the path
+// and query of the callback the issuer sends the user back to.
+func authorizeOIDC(t *testing.T, ts *testServer, issuer *fakeIssuer) string {
+	t.Helper()
+
+	code, header, _ := ts.get(t, "/user/login/oidc")
+	if code != http.StatusFound {
+		t.Fatalf("starting single sign-on: want %d; got %d", http.StatusFound, code)
+	}
+
+	client := *issuer.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	rs, err := client.Get(header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	callback, err := url.Parse(rs.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.RequestURI()
+}
+
+func TestOIDCCallback(t *testing.T) {
+	app := newTestApplication(t)
+	issuer := newFakeIssuer(t)
+	ts := newOIDCTestServer(t, app, issuer)
+
+	// A local account, which signing in with the same verified email address links to.
+	aliceID, err := app.users.Insert("Alice", "alice@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Activate(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An account someone else signed up with Bob's address, without verifying it, which Bob claims.
+	_, err = app.users.Insert("Squatter", "bob@example.com", "squatterpa55")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A verified account an administrator has deactivated, which stays locked out.
+	daveID, err := app.users.Insert("Dave", "dave@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Activate(daveID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.SetActive(daveID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		subject       string
+		email         string
+		emailVerified bool
+		wrongNonce    bool
+		wantLocation  string
+		wantBody      string // On the page redirected to
+		wantUsers     int
+	}{
+		{"New user", "new-subject", "carol@example.com", true, false, "/", "carol@example.com", 4},
+		{"Nonce mismatch", "other-subject", "erin@example.com", true, true, "/user/login",
+			"Single sign-on failed", 4},
+		{"Email not verified", "other-subject", "erin@example.com", false, false, "/user/login",
+			"verified your email address", 4},
+		{"Existing account linked by email", "alice-subject", "ALICE@example.com", true, false, "/",
+			"alice@example.com", 4},
+		{"Unverified account claimed", "bob-subject", "bob@example.com", true, false, "/", "bob@example.com", 4},
+		{"Deactivated account", "dave-subject", "dave@example.com", true, false, "/user/login",
+			"account isn&#39;t active", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Start each case logged out, with an empty cookie jar.
+			jar, err := cookiejar.New(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts.Client().Jar = jar
+
+			issuer.subject, issuer.email, issuer.emailVerified = tt.subject, tt.email, tt.emailVerified
+			issuer.name = "SSO User"
+			issuer.wrongNonce = tt.wrongNonce
+
+			code, header, _ := ts.get(t, authorizeOIDC(t, ts, issuer))
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+			}
+			if header.Get("Location") != tt.wantLocation {
+				t.Fatalf("want location %q; got %q", tt.wantLocation, header.Get("Location"))
+			}
+
+			// A user who has signed in can see their account; otherwise the login page shows the message.
+			page := tt.wantLocation
+			if page == "/" {
+				page = "/user/profile"
+			}
+			code, _, body := ts.get(t, page)
+			if code != http.StatusOK {
+				t.Fatalf("%s: want %d; got %d", page, http.StatusOK, code)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("%s: want body to contain %q", page, tt.wantBody)
+			}
+
+			users, err := app.users.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.wantUsers {
+				t.Errorf("want %d users; got %d", tt.wantUsers, len(users))
+			}
+		})
+	}
+
+	// The password the squatter signed up with no longer works on the claimed account.
+	_, err = app.users.Authenticate("bob@example.com", "squatterpa55")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("squatter's password: want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+}
+
+func TestOIDCCallbackWrongState(t *testing.T) {
+	app := newTestApplication(t)
+	issuer := newFakeIssuer(t)
+	ts := newOIDCTestServer(t, app, issuer)
+
+	issuer.subject, issuer.email, issuer.emailVerified = "subject", "carol@example.com", true
+
+	callback, err := url.Parse(authorizeOIDC(t, ts, issuer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rightQuery := callback.RawQuery
+	q := callback.Query()
+	q.Set("state", "wrong-state")
+	callback.RawQuery = q.Encode()
+
+	code, _, _ := ts.get(t, callback.RequestURI())
+	if code != http.StatusBadRequest {
+		t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+	}
+
+	// The state is removed from the session by the first callback, so the right one doesn't work afterwards.
+	callback.RawQuery = rightQuery
+	code, _, _ = ts.get(t, callback.RequestURI())
+	if code != http.StatusBadRequest {
+		t.Errorf("right state after a failed callback: want %d; got %d", http.StatusBadRequest, code)
+	}
+
+	users, err := app.users.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 0 {
+		t.Errorf("want no users; got %d", len(users))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// The languageExtensions map gives the file extension used when downloading a code snippet in each of
+// models.Languages. Languages which aren't listed are downloaded as .txt files.
+var languageExtensions = map[string]string{
+	"bash":       "sh",
+	"c":          "c",
+	"cpp":        "cpp",
+	"csharp":     "cs",
+	"css":        "css",
+	"diff":       "diff",
+	"docker":     "dockerfile",
+	"go":         "go",
+	"html":       "html",
+	"ini":        "ini",
+	"java":       "java",
+	"javascript": "js",
+	"json":       "json",
+	"kotlin":     "kt",
+	"markdown":   "md",
+	"php":        "php",
+	"python":     "py",
+	"ruby":       "rb",
+	"rust":       "rs",
+	"sql":        "sql",
+	"swift":      "swift",
+	"toml":       "toml",
+	"typescript": "ts",
+	"xml":        "xml",
+	"yaml":       "yaml",
+}
+
+// filenameRX matches the runs of characters in a snippet's title which are left out of its download filename.
+var filenameRX = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// The snippetFilename function returns the filename a snippet is downloaded as, made from its title and an
+// extension for its format and language, e.g. "hello-world.go".
+func snippetFilename(s *models.Snippet) string {
+	name := strings.Trim(filenameRX.ReplaceAllString(s.Title, "-"), "-")
+	if name == "" {
+		name = "snippet"
+	}
+
+	ext := "txt"
+	switch s.Format {
+	case models.FormatMarkdown:
+		ext = "md"
+	case models.FormatCode:
+		if e, ok := languageExtensions[s.Language]; ok {
+			ext = e
+		}
+	}
+	return name + "." + ext
+}
+
+// The snippetFromURL helper loads the snippet named by the slug in the URL, with the same expiry and visibility
+// rules as the snippet page. An old URL with the snippet's ID is redirected to the URL made from format and the
+// slug, see redirectSnippetID. If it returns false, a response has already been sent and the handler should
+// return.
+func (app *Application) snippetFromURL(w http.ResponseWriter, r *http.Request, format string) (*models.Snippet, bool) {
+	slug := chi.URLParam(r, "slug")
+	s, err := app.snippets.GetBySlug(slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.redirectSnippetID(w, r, slug, format)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return s, true
+}
+
+// rawSnippet function sends the content of a snippet as plain text, e.g. for use with curl. A password protected
+// snippet must be unlocked first, otherwise the response is 403 Forbidden.
+func (app *Application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s/raw")
+	if !ok {
+		return
+	}
+	if app.snippetLocked(r, s) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, s.Content)
+}
+
+// downloadSnippet function sends the content of a snippet as a file attachment, named by snippetFilename. It
+// follows the same rules as rawSnippet.
+func (app *Application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s/download")
+	if !ok {
+		return
+	}
+	if app.snippetLocked(r, s) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": snippetFilename(s)}))
+	io.WriteString(w, s.Content)
+}
+
+// embedSnippet function shows a snippet on its own, without the rest of the site, for other sites to include in
+// an iframe. It's the only page which may be framed, so the X-Frame-Options header set by the secureHeaders
+// middleware is removed. The content of a password protected snippet is only shown once it's unlocked on the
+// snippet page, which browsers usually won't allow from inside another site.
+func (app *Application) embedSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s/embed")
+	if !ok {
+		return
+	}
+
+	w.Header().Del("X-Frame-Options")
+	app.render(w, r, "embed.page.gohtml", &templateData{
+		Snippet: s,
+		Locked:  app.snippetLocked(r, s),
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	aliceID, err := app.users.Insert("Alice", "alice@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "package main\n\nfunc main() {}\n"
+	slug, err := app.snippets.Insert("Hello, world!", content, models.FormatCode, "go", "7",
+		models.VisibilityPublic, "", aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected, err := app.snippets.Insert("Secret", content, models.FormatCode, "go", "7",
+		models.VisibilityPublic, "s3cret", aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A header wanted as "" must not be sent at all.
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantHeader map[string]string
+		wantBody   string
+	}{
+		{"Raw", "/snippet/" + slug + "/raw", http.StatusOK, map[string]string{
+			"Content-Type":           "text/plain; charset=utf-8",
+			"X-Content-Type-Options": "nosniff",
+			"Content-Disposition":    "",
+		}, content},
+		{"Download", "/snippet/" + slug + "/download", http.StatusOK, map[string]string{
+			"Content-Type":           "text/plain; charset=utf-8",
+			"X-Content-Type-Options": "nosniff",
+			"Content-Disposition":    "attachment; filename=Hello-world.go",
+		}, content},
+		{"Embed", "/snippet/" + slug + "/embed", http.StatusOK, map[string]string{
+			"Content-Type":    "text/html; charset=utf-8",
+			"X-Frame-Options": "",
+		}, "Hello, world!"},
+		{"Snippet page", "/snippet/" + slug, http.StatusOK, map[string]string{
+			"X-Frame-Options": "deny",
+		}, "Hello, world!"},
+		{"Raw, locked", "/snippet/" + protected + "/raw", http.StatusForbidden, nil, ""},
+		{"Download, locked", "/snippet/" + protected + "/download", http.StatusForbidden, nil, ""},
+		{"Embed, locked", "/snippet/" + protected + "/embed", http.StatusOK, nil, "This snippet is password protected"},
+		{"Raw, unknown slug", "/snippet/AAAAAAAAAAAA/raw", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			for name, want := range tt.wantHeader {
+				if got := header.Get(name); got != want {
+					t.Errorf("want %s %q; got %q", name, want, got)
+				}
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Once the snippet is unlocked on its page, its raw content can be read too.
+	code, _, _ := ts.submitForm(t, "/snippet/"+protected, "/snippet/"+protected+"/unlock",
+		url.Values{"password": {"s3cret"}})
+	if code != http.StatusSeeOther {
+		t.Fatalf("unlock: want %d; got %d", http.StatusSeeOther, code)
+	}
+	code, _, body := ts.get(t, "/snippet/"+protected+"/raw")
+	if code != http.StatusOK || body != content {
+		t.Errorf("raw after unlocking: want %d with the content; got %d with %q", http.StatusOK, code, body)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// The revisionIndex function returns the index in revisions of the revision with the ID given in the query string
+// parameter named key, or fallback if the parameter is left out. The boolean is false if the parameter isn't the
+// ID of one of the revisions.
+func revisionIndex(r *http.Request, revisions []*models.Revision, key string, fallback int) (int, bool) {
+	param := r.URL.Query().Get(key)
+	if param == "" {
+		return fallback, true
+	}
+
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, false
+	}
+	for i, rev := range revisions {
+		if rev.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// The revisionDiff function returns a unified diff of the content of two revisions, or an empty string if the
+// content is the same. If from is nil, the diff shows all of the content of to as added.
+func revisionDiff(from, to *models.Revision) (string, error) {
+	diff := difflib.UnifiedDiff{
+		B:        difflib.SplitLines(to.Content),
+		FromFile: "/dev/null",
+		ToFile:   to.Title,
+		ToDate:   humanDate(to.Created),
+		Context:  3,
+	}
+	if from != nil {
+		diff.A = difflib.SplitLines(from.Content)
+		diff.FromFile = from.Title
+		diff.FromDate = humanDate(from.Created)
+	}
+	return difflib.GetUnifiedDiffString(diff)
+}
+
+// snippetHistory function lists the revisions of a snippet, newest first, which anyone who can see the snippet
+// can compare. A password protected snippet has to be unlocked on its page first.
+func (app *Application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s/history")
+	if !ok {
+		return
+	}
+	if app.snippetLocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "history.page.gohtml", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+// snippetDiff function shows the changes between two revisions of a snippet as a unified diff. The revisions are
+// given by their IDs in the from and to query string parameters. To defaults to the newest revision, and from to the
+// revision before it.
+func (app *Application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r, "/snippet/%s/diff")
+	if !ok {
+		return
+	}
+	if app.snippetLocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Revisions are newest first, so the revision before "to" is the next one in the list. The oldest revision
+	// has nothing before it, so all of its content shows as added.
+	to, ok := revisionIndex(r, revisions, "to", 0)
+	if !ok || to >= len(revisions) {
+		app.notFound(w, r)
+		return
+	}
+	from, ok := revisionIndex(r, revisions, "from", to+1)
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	td := &templateData{
+		Snippet:    s,
+		Revisions:  revisions,
+		ToRevision: revisions[to],
+	}
+	if from < len(revisions) {
+		td.FromRevision = revisions[from]
+	}
+
+	td.Diff, err = revisionDiff(td.FromRevision, td.ToRevision)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "diff.page.gohtml", td)
+}
+
+// restoreRevision function replaces the title and content of a snippet with those of one of its earlier
+// revisions, which saves them as a new revision. The format, language and visibility are left as they are. The
+// snippet is loaded by the requireSnippetOwner middleware, which also checks that the current user is its author.
+func (app *Application) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	s := r.Context().Value(contextKeySnippet).(*models.Snippet)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	rev, err := app.snippets.Revision(s.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.snippets.Update(s.ID, rev.Title, rev.Content, s.Format, s.Language, s.Visibility, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "toast", fmt.Sprintf("Snippet restored to the version from %s", humanDate(rev.Created)))
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+}
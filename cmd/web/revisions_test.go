@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestSnippetRevisions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	aliceID := ts.login(t, app, "Alice", "alice@example.com", "pa55word1234")
+
+	slug, err := app.snippets.Insert("Lines", "line one\nline two\n", models.FormatCode, "plaintext", "7",
+		models.VisibilityPublic, "", aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippets.GetBySlug(slug, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.snippets.Update(s.ID, "Lines", "line one\nline 2\n", s.Format, s.Language, s.Visibility, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Revisions are listed newest first.
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("want 2 revisions; got %d", len(revisions))
+	}
+	newest, oldest := strconv.Itoa(revisions[0].ID), strconv.Itoa(revisions[1].ID)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []string
+	}{
+		{"History", "/snippet/" + slug + "/history", http.StatusOK, []string{"/diff?to=" + newest}},
+		{"Latest change", "/snippet/" + slug + "/diff", http.StatusOK, []string{"-line two", "+line 2"}},
+		{"Reversed", "/snippet/" + slug + "/diff?from=" + newest + "&to=" + oldest, http.StatusOK,
+			[]string{"-line 2", "+line two"}},
+		{"Oldest", "/snippet/" + slug + "/diff?to=" + oldest, http.StatusOK,
+			[]string{"New snippet", "+line one", "+line two"}},
+		{"Same revision", "/snippet/" + slug + "/diff?from=" + newest + "&to=" + newest, http.StatusOK,
+			[]string{"The content is the same"}},
+		{"Unknown revision", "/snippet/" + slug + "/diff?to=999", http.StatusNotFound, nil},
+		{"Invalid revision", "/snippet/" + slug + "/diff?from=abc", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+		})
+	}
+
+	restore := func(id string) (int, http.Header, string) {
+		return ts.submitForm(t, "/snippet/"+slug+"/history", "/snippet/"+slug+"/revisions/"+id+"/restore",
+			url.Values{})
+	}
+
+	code, _, _ := restore("999")
+	if code != http.StatusNotFound {
+		t.Errorf("restoring an unknown revision: want %d; got %d", http.StatusNotFound, code)
+	}
+
+	// Restoring the oldest revision brings back its content and saves it as a new revision.
+	code, header, _ := restore(oldest)
+	if code != http.StatusSeeOther || header.Get("Location") != "/snippet/"+slug {
+		t.Fatalf("restore: want %d to %q; got %d to %q", http.StatusSeeOther, "/snippet/"+slug, code,
+			header.Get("Location"))
+	}
+	s, err = app.snippets.GetBySlug(slug, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Content != "line one\nline two\n" {
+		t.Errorf("want restored content; got %q", s.Content)
+	}
+	revisions, err = app.snippets.Revisions(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Errorf("want 3 revisions; got %d", len(revisions))
+	}
+}
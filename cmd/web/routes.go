@@ -3,28 +3,122 @@ package main
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/justinas/alice"
+	"github.com/rlr524/snippetbox/pkg/models"
 	"net/http"
 )
 
-// TODO: See docs.md middleware section for a detailed explanation of this todo
+// Routes are split into middleware groups. The standard middleware is used for every request, the dynamic
+// group adds session handling and CSRF protection for the application pages, and the protected group nested
+// within it additionally requires the user to be logged in.
 func (app *Application) routes() http.Handler {
 	// Use the alice package for middleware chain with the standard middleware used for every request
-	// middleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders, app.session.Enable, app.requireAuthentication)
-	middleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders, app.session.Enable)
+	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// And a second alice chain for the dynamic middleware used by the application pages. The noSurf and
+	// authenticate middleware must come after session.Enable, as they use the session.
+	dynamic := alice.New(app.session.Enable, app.noSurf, app.authenticate)
 	r := chi.NewRouter()
 
-	r.Get("/", app.home)
-	r.Route("/snippet", func(r chi.Router) {
-		r.Get("/create", app.createSnippetForm)
-		r.Post("/create", app.createSnippet)
-		r.Get("/{id:[0-9]+}", app.showSnippet)
+	// Dynamic routes, which need the session data and CSRF protection.
+	r.Group(func(r chi.Router) {
+		r.Use(dynamic.Then)
+
+		r.Get("/", app.home)
+		r.Get("/snippet/{slug}", app.showSnippet)
+		r.Post("/snippet/{slug}/unlock", app.unlockSnippet)
+		r.Get("/snippet/{slug}/raw", app.rawSnippet)
+		r.Get("/snippet/{slug}/download", app.downloadSnippet)
+		r.Get("/snippet/{slug}/embed", app.embedSnippet)
+		r.Get("/snippet/{slug}/history", app.snippetHistory)
+		r.Get("/snippet/{slug}/diff", app.snippetDiff)
+		r.Get("/user/signup", app.signupUserForm)
+		r.Post("/user/signup", app.signupUser)
+		r.Get("/user/login", app.loginUserForm)
+		r.Post("/user/login", app.loginUser)
+		r.Get("/user/verify", app.verifyUser)
+		r.Get("/user/verify/resend", app.resendVerificationForm)
+		r.Post("/user/verify/resend", app.resendVerification)
+		r.Get("/user/login/two-factor", app.loginTwoFactorForm)
+		r.Post("/user/login/two-factor", app.loginTwoFactor)
+		if app.oidc != nil {
+			r.Get("/user/login/oidc", app.oidcLogin)
+			r.Get("/user/login/oidc/callback", app.oidcCallback)
+		}
+		r.Get("/user/password/forgot", app.forgotPasswordForm)
+		r.Post("/user/password/forgot", app.forgotPassword)
+		r.Get("/user/password/reset", app.resetPasswordForm)
+		r.Post("/user/password/reset", app.resetPassword)
+
+		// Protected routes, which can only be used by an authenticated user.
+		r.Group(func(r chi.Router) {
+			r.Use(app.requireAuthentication)
+
+			r.Get("/snippet/create", app.createSnippetForm)
+			r.Post("/snippet/create", app.createSnippet)
+			r.Post("/snippet/{slug}/fork", app.forkSnippet)
+			r.Post("/user/logout", app.logoutUser)
+			r.Get("/user/profile", app.userProfile)
+			r.Get("/user/password", app.changePasswordForm)
+			r.Post("/user/password", app.changePassword)
+			r.Get("/user/two-factor", app.twoFactorForm)
+			r.Post("/user/two-factor/enable", app.enableTwoFactor)
+			r.Post("/user/two-factor/disable", app.disableTwoFactor)
+			r.Get("/user/tokens", app.listTokens)
+			r.Post("/user/tokens", app.createToken)
+			r.Post("/user/tokens/{id:[0-9]+}/revoke", app.revokeToken)
+
+			// Routes which change an existing snippet, which are further restricted to the snippet's author.
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireSnippetOwner)
+
+				r.Get("/snippet/{slug}/edit", app.editSnippetForm)
+				r.Post("/snippet/{slug}/edit", app.editSnippet)
+				r.Post("/snippet/{slug}/delete", app.deleteSnippet)
+				r.Post("/snippet/{slug}/revisions/{id:[0-9]+}/restore", app.restoreRevision)
+			})
+
+			// The admin section. Moderators can remove any snippet, and only admins can manage users.
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireRole(models.RoleModerator))
+
+				r.Post("/admin/snippets/{slug}/delete", app.adminDeleteSnippet)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireRole(models.RoleAdmin))
+
+				r.Get("/admin", app.adminUsers)
+				r.Post("/admin/users/{id:[0-9]+}/activate", app.adminActivateUser)
+				r.Post("/admin/users/{id:[0-9]+}/deactivate", app.adminDeactivateUser)
+				r.Post("/admin/users/{id:[0-9]+}/role", app.adminSetRole)
+			})
+		})
 	})
-	r.Route("/user", func(r chi.Router) {
-		r.Get("/signup", app.signupUserForm)
-		r.Post("/signup", app.signupUser)
-		r.Get("/login", app.loginUserForm)
-		r.Post("/login", app.loginUser)
-		r.Post("/logout", app.logoutUser)
+
+	// Versioned JSON API routes. These can be authenticated with either a personal access token or the session.
+	// They don't use the CSRF middleware, which relies on a hidden form field; instead requireJSON only accepts
+	// request bodies declared as JSON.
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(app.session.Enable, app.authenticate, app.authenticateToken, app.requireJSON)
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			app.notFound(w, r)
+		})
+		r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+			app.clientError(w, r, http.StatusMethodNotAllowed)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.requireScope(models.ScopeSnippetsRead))
+
+			r.Get("/snippets", app.apiListSnippets)
+			r.Get("/snippets/{slug}", app.apiShowSnippet)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.requireAuthentication, app.requireScope(models.ScopeSnippetsWrite))
+
+			r.Post("/snippets", app.apiCreateSnippet)
+			r.With(app.requireSnippetOwner).Put("/snippets/{slug}", app.apiUpdateSnippet)
+			r.With(app.requireSnippetOwner).Delete("/snippets/{slug}", app.apiDeleteSnippet)
+		})
 	})
 
 	// Create a file server which serves files out of the "./ui/static" directory. Note that
@@ -36,8 +130,7 @@ func (app *Application) routes() http.Handler {
 	r.Handle("/static", http.NotFoundHandler())
 	r.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
-	// Wrap the return statement in the recoverPanic and logRequest middleware, then pass the servemux as
-	// the "next" parameter to the secureHeaders middleware. Because secureHeaders is just a function, and
-	// the function returns a http.Handler, there is nothing else to do.
-	return middleware.Then(r)
+	// Wrap the router in the standard middleware chain. Because each middleware is just a function that
+	// returns a http.Handler, there is nothing else to do.
+	return standard.Then(r)
 }
@@ -13,12 +13,36 @@ import (
 // via the Applications struct defined in main.go. Because of this, the model is referenced using the actual
 // model name, Snippet, not the alias it's assigned in the Application struct, which is SnippetsModel.
 type templateData struct {
-	CurrentYear     int
-	Toast           string
-	Form            *forms.Form
-	IsAuthenticated bool
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
+	CurrentYear         int
+	Toast               string
+	Form                *forms.Form
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	User                *models.User
+	Tokens              []*models.Token
+	NewToken            string
+	Scopes              []string
+	TwoFactorSecret     string
+	TwoFactorURI        string
+	TwoFactorQR         template.URL
+	RecoveryCodes       []string
+	OIDCEnabled         bool
+	IsModerator         bool
+	IsAdmin             bool
+	Users               []*models.User
+	Roles               []string
+	Formats             []string
+	Languages           []string
+	Locked              bool
+	BaseURL             string
+	Revisions           []*models.Revision
+	FromRevision        *models.Revision
+	ToRevision          *models.Revision
+	Diff                string
+	Parent              *models.Snippet
 }
 
 // The humanDate function converts ISO 8601 time format to a more human-readable form. See the documentation
@@ -31,6 +55,8 @@ func humanDate(t time.Time) string {
 // The function map object acts as a lookup between the names of custom template functions and the functions themselves
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlight,
+	"markdown":  markdown,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golangcollege/sessions"
+	"github.com/rlr524/snippetbox/pkg/limiter"
+	"github.com/rlr524/snippetbox/pkg/mailer"
+	"github.com/rlr524/snippetbox/pkg/models/memory"
+)
+
+// The newTestApplication function returns an Application backed by the memory storage backend, which writes its
+// emails to a temporary directory that can be read with readMails.
+func newTestApplication(t *testing.T) *Application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache("./../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge")
+	session := sessions.New(secret)
+	session.Lifetime = 12 * time.Hour
+	session.Secure = true
+
+	db := memory.NewDB()
+	return &Application{
+		errorLog:      log.New(io.Discard, "", 0),
+		infoLog:       log.New(io.Discard, "", 0),
+		session:       session,
+		snippets:      &memory.SnippetModel{DB: db},
+		users:         &memory.UserModel{DB: db},
+		tokens:        &memory.TokenModel{DB: db},
+		templateCache: templateCache,
+		mailer:        &mailer.FileMailer{Dir: t.TempDir(), From: "Snippetbox <no-reply@snippetbox.local>"},
+		baseURL:       "https://snippetbox.test",
+		secret:        secret,
+		loginLimiter:  limiter.New(limiter.NewMemoryStore(15 * time.Minute)),
+	}
+}
+
+// testServer wraps an httptest.Server with a client that keeps cookies between requests and doesn't follow
+// redirects, so that tests can check where a handler redirects to.
+type testServer struct {
+	*httptest.Server
+}
+
+// The newTestServer function starts a TLS test server for the handler. The session cookie is secure, so the
+// server has to use TLS for the client to send it back.
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testServer{ts}
+}
+
+// The get method makes a GET request to the test server and returns the response's status code, headers and body.
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+// The postForm method makes a POST request with the form to the test server and returns the response's status
+// code, headers and body.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+// The request method makes a request to the test server with a JSON body, if body isn't empty, and a personal
+// access token, if token isn't empty. It returns the response's status code, headers and body.
+func (ts *testServer) request(t *testing.T, method, urlPath, token, body string) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+// The submitForm method fetches the page with a form, to get a CSRF token, and then posts the form with the token
+// added to urlPath.
+func (ts *testServer) submitForm(t *testing.T, page, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+
+	_, _, body := ts.get(t, page)
+	form.Set("csrf_token", extractCSRFToken(t, body))
+	return ts.postForm(t, urlPath, form)
+}
+
+// The login method signs up a user with the given details, verifies their email address through the store and
+// logs them in, returning their ID.
+func (ts *testServer) login(t *testing.T, app *Application, name, email, password string) int {
+	t.Helper()
+
+	id, err := app.users.Insert(name, email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Activate(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, _ := ts.submitForm(t, "/user/login", "/user/login", url.Values{
+		"email":    {email},
+		"password": {password},
+	})
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in %s: want %d; got %d", email, http.StatusSeeOther, code)
+	}
+	return id
+}
+
+// The readResponse function reads and closes the body of a response from the test server
+func readResponse(t *testing.T, rs *http.Response) (int, http.Header, string) {
+	t.Helper()
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
+
+// The extractCSRFToken function returns the CSRF token from a page's form
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+	return html.UnescapeString(matches[1])
+}
+
+// The readMails function returns the emails the application's FileMailer has written, oldest first, with their
+// line endings turned back into "\n".
+func readMails(t *testing.T, app *Application) []string {
+	t.Helper()
+
+	dir := app.mailer.(*mailer.FileMailer).Dir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are named so that they sort in the order they were sent.
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var mails []string
+	for _, name := range names {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mails = append(mails, strings.ReplaceAll(string(b), "\r\n", "\n"))
+	}
+	return mails
+}
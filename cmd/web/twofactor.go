@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/rlr524/snippetbox/pkg/forms"
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// Two-factor codes are the standard six digit, 30 second TOTP codes which authenticator apps generate.
+const totpPeriod = 30
+
+// The pendingTwoFactorTTL constant is how long a user has to give a code after their password, before they have
+// to log in again.
+const pendingTwoFactorTTL = 5 * time.Minute
+
+// The totpCodeRX regular expression matches a TOTP code, to tell it apart from a recovery code.
+var totpCodeRX = regexp.MustCompile(`^[0-9]{6}$`)
+
+// The validateTOTP helper checks a code against a TOTP secret and returns the time step it is valid for. To allow
+// for clocks which are a little out, the codes for the time steps either side of the current one are accepted too.
+func validateTOTP(secret, code string, t time.Time) (int64, bool) {
+	for _, skew := range []int64{0, -1, 1} {
+		counter := t.Unix()/totpPeriod + skew
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(counter), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// twoFactorForm function shows the two-factor login settings of the authenticated user. If it isn't turned on
+// yet, a new TOTP secret is generated and shown as a QR code for the user to scan with their authenticator app.
+// The secret is kept in the session until the user confirms it with a code.
+func (app *Application) twoFactorForm(w http.ResponseWriter, r *http.Request) {
+	app.renderTwoFactor(w, r, forms.New(nil))
+}
+
+// The renderTwoFactor helper renders the two-factor settings page with the given form
+func (app *Application) renderTwoFactor(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	u, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	td := &templateData{Form: form, User: u}
+	if !u.TwoFactorEnabled {
+		key, err := app.pendingTOTPKey(r, u)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		img, err := key.Image(200, 200)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		var buf bytes.Buffer
+		err = png.Encode(&buf, img)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		td.TwoFactorSecret = key.Secret()
+		td.TwoFactorURI = key.URL()
+		td.TwoFactorQR = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+	app.render(w, r, "twofactor.page.gohtml", td)
+}
+
+// The pendingTOTPKey helper returns the TOTP key the user is enrolling with. The key's otpauth URI is kept in the
+// session and the same key is used until the user confirms it, so reloading the page doesn't invalidate a QR
+// code they have already scanned.
+func (app *Application) pendingTOTPKey(r *http.Request, u *models.User) (*otp.Key, error) {
+	if uri := app.session.GetString(r, "pendingTOTPKey"); uri != "" {
+		return otp.NewKeyFromURL(uri)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "Snippetbox",
+		AccountName: u.Email,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	app.session.Put(r, "pendingTOTPKey", key.URL())
+	return key, nil
+}
+
+// enableTwoFactor function turns on two-factor login, once the user has confirmed the pending secret with a code
+// from their authenticator app, and shows their recovery codes
+func (app *Application) enableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+
+	uri := app.session.GetString(r, "pendingTOTPKey")
+	if uri == "" {
+		http.Redirect(w, r, "/user/two-factor", http.StatusSeeOther)
+		return
+	}
+	key, err := otp.NewKeyFromURL(uri)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	secret := key.Secret()
+
+	counter, ok := validateTOTP(secret, form.Get("code"), time.Now())
+	if form.Valid() && !ok {
+		form.FormErrors.Add("code", "This code is incorrect, please try the next one from your app")
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, form)
+		return
+	}
+
+	codes, err := models.NewRecoveryCodes(models.RecoveryCodeCount)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	err = app.users.EnableTwoFactor(id, secret, codes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	// The code used to confirm the secret can't be used again to log in.
+	err = app.users.UseTOTPCounter(id, counter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.session.Remove(r, "pendingTOTPKey")
+
+	u, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "twofactor.page.gohtml", &templateData{
+		Form:          forms.New(nil),
+		User:          u,
+		RecoveryCodes: codes,
+	})
+}
+
+// disableTwoFactor function turns off two-factor login, after checking either the user's password or a code from
+// their authenticator app or a recovery code. Users who sign in through single sign-on have no password they know,
+// so a code is the only way they can prove it's them. Failed attempts are limited in the same way as failed
+// codes at login.
+func (app *Application) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	if form.Get("password") == "" && form.Get("code") == "" {
+		form.FormErrors.Add("generic", "Please enter your password or a code")
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, form)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+	ipKey, userKey := "ip:"+clientIP(r), "two-factor:"+strconv.Itoa(id)
+	failures, wait, err := app.loginLimiter.Attempt(ipKey, userKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.infoLog.Printf("Turning off two-factor login for user %d from %s refused, retry in %s", id,
+			clientIP(r), wait.Round(time.Second))
+		form.FormErrors.Add("generic", fmt.Sprintf("Too many failed attempts, please try again in %s",
+			humanDuration(wait)))
+		app.renderTwoFactor(w, r, form)
+		return
+	}
+
+	if form.Get("code") != "" {
+		err = app.checkTwoFactorCode(id, form.Get("code"))
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.infoLog.Printf("Failed two-factor code for user %d from %s (%d failures)", id, clientIP(r), failures)
+			form.FormErrors.Add("code", "This code is incorrect")
+			app.renderTwoFactor(w, r, form)
+			return
+		}
+	} else {
+		var u *models.User
+		u, err = app.users.Get(id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		_, err = app.users.Authenticate(u.Email, form.Get("password"))
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.infoLog.Printf("Failed password for user %d from %s (%d failures)", id, clientIP(r), failures)
+			form.FormErrors.Add("password", "Password is incorrect")
+			app.renderTwoFactor(w, r, form)
+			return
+		}
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.loginLimiter.Release(ipKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.loginLimiter.Reset(userKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.users.DisableTwoFactor(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "toast", "Two-factor login has been turned off.")
+
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// The startTwoFactorLogin helper remembers in the session the user who has proved who they are with their password
+// or through single sign-on, and asks them for a code.
+func (app *Application) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, id int) {
+	app.session.Put(r, "pendingTwoFactorUserID", id)
+	// The time is kept as Unix seconds, as the session only encodes basic types without registering others.
+	app.session.Put(r, "pendingTwoFactorAt", int(time.Now().Unix()))
+	http.Redirect(w, r, "/user/login/two-factor", http.StatusSeeOther)
+}
+
+// The pendingTwoFactorUserID helper returns the ID of the user waiting to give a code, or 0 if there isn't one or
+// they took longer than pendingTwoFactorTTL.
+func (app *Application) pendingTwoFactorUserID(r *http.Request) int {
+	started := time.Unix(int64(app.session.GetInt(r, "pendingTwoFactorAt")), 0)
+	if time.Since(started) > pendingTwoFactorTTL {
+		app.clearTwoFactorLogin(r)
+		return 0
+	}
+	return app.session.GetInt(r, "pendingTwoFactorUserID")
+}
+
+// The clearTwoFactorLogin helper forgets the user waiting to give a code, once they've logged in or have started
+// logging in again.
+func (app *Application) clearTwoFactorLogin(r *http.Request) {
+	app.session.Remove(r, "pendingTwoFactorUserID")
+	app.session.Remove(r, "pendingTwoFactorAt")
+}
+
+// loginTwoFactorForm function shows the second step of logging in, which asks a user who has given the right
+// password for a code from their authenticator app or a recovery code
+func (app *Application) loginTwoFactorForm(w http.ResponseWriter, r *http.Request) {
+	if app.pendingTwoFactorUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	app.render(w, r, "login_two_factor.page.gohtml", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// loginTwoFactor function checks the code given in the second step of logging in and, if it's right, logs the
+// user in. Failed codes are limited in the same way as failed passwords.
+func (app *Application) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id := app.pendingTwoFactorUserID(r)
+	if id == 0 {
+		app.session.Put(r, "toast", "It took too long to give a code, please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	if !form.Valid() {
+		app.render(w, r, "login_two_factor.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	ipKey, userKey := "ip:"+clientIP(r), "two-factor:"+strconv.Itoa(id)
+	failures, wait, err := app.loginLimiter.Attempt(ipKey, userKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.infoLog.Printf("Two-factor attempt for user %d from %s refused, retry in %s", id, clientIP(r),
+			wait.Round(time.Second))
+		form.FormErrors.Add("generic", fmt.Sprintf("Too many failed attempts, please try again in %s",
+			humanDuration(wait)))
+		app.render(w, r, "login_two_factor.page.gohtml", &templateData{Form: form})
+		return
+	}
+
+	err = app.checkTwoFactorCode(id, form.Get("code"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.infoLog.Printf("Failed two-factor code for user %d from %s (%d failures)", id, clientIP(r), failures)
+			form.FormErrors.Add("generic", "This code is incorrect")
+			app.render(w, r, "login_two_factor.page.gohtml", &templateData{Form: form})
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.loginLimiter.Release(ipKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.loginLimiter.Reset(userKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.clearTwoFactorLogin(r)
+	app.completeLogin(w, r, id)
+}
+
+// The checkTwoFactorCode helper checks a code from a user's authenticator app, or one of their recovery codes,
+// and marks it as used. If the code isn't valid, or has been used before, return the models.ErrInvalidToken error.
+func (app *Application) checkTwoFactorCode(id int, code string) error {
+	if !totpCodeRX.MatchString(code) {
+		return app.users.UseRecoveryCode(id, code)
+	}
+
+	secret, err := app.users.TOTPSecret(id)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return models.ErrInvalidToken
+	}
+
+	counter, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return models.ErrInvalidToken
+	}
+	return app.users.UseTOTPCounter(id, counter)
+}
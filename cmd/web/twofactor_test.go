@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestDisableTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	id := ts.login(t, app, "Alice", "alice@example.com", "pa55word1234")
+
+	tests := []struct {
+		name         string
+		password     string
+		code         string // "totp" and "recovery" are replaced with a valid code of that kind
+		wantCode     int
+		wantLocation string
+		wantBody     string
+		wantEnabled  bool
+	}{
+		{"Neither", "", "", http.StatusOK, "", "Please enter your password or a code", true},
+		{"Wrong password", "wrongpa55word", "", http.StatusOK, "", "Password is incorrect", true},
+		{"Wrong code", "", "not-a-code", http.StatusOK, "", "This code is incorrect", true},
+		{"Password", "pa55word1234", "", http.StatusSeeOther, "/user/profile", "", false},
+		{"TOTP code", "", "totp", http.StatusSeeOther, "/user/profile", "", false},
+		{"Recovery code", "", "recovery", http.StatusSeeOther, "/user/profile", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each case starts with two-factor login turned on, with a new secret and recovery codes.
+			key, err := totp.Generate(totp.GenerateOpts{Issuer: "Snippetbox", AccountName: "alice@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes, err := models.NewRecoveryCodes(models.RecoveryCodeCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = app.users.EnableTwoFactor(id, key.Secret(), codes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code := tt.code
+			switch code {
+			case "totp":
+				code, err = totp.GenerateCode(key.Secret(), time.Now())
+				if err != nil {
+					t.Fatal(err)
+				}
+			case "recovery":
+				code = codes[0]
+			}
+
+			status, header, body := ts.submitForm(t, "/user/two-factor", "/user/two-factor/disable",
+				url.Values{"password": {tt.password}, "code": {code}})
+			if status != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, status)
+			}
+			if header.Get("Location") != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, header.Get("Location"))
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			u, err := app.users.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.TwoFactorEnabled != tt.wantEnabled {
+				t.Errorf("want two-factor login enabled %t; got %t", tt.wantEnabled, u.TwoFactorEnabled)
+			}
+		})
+	}
+}
+
+func TestLoginTwoFactorPending(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.users.Insert("Alice", "alice@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Activate(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "Snippetbox", AccountName: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.EnableTwoFactor(id, key.Secret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(password string) (int, http.Header, string) {
+		return ts.submitForm(t, "/user/login", "/user/login",
+			url.Values{"email": {"alice@example.com"}, "password": {password}})
+	}
+
+	// The right password asks for a code.
+	code, header, _ := login("pa55word1234")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login/two-factor" {
+		t.Fatalf("password: want %d to /user/login/two-factor; got %d to %q", http.StatusSeeOther, code,
+			header.Get("Location"))
+	}
+	code, _, _ = ts.get(t, "/user/login/two-factor")
+	if code != http.StatusOK {
+		t.Fatalf("code form: want %d; got %d", http.StatusOK, code)
+	}
+
+	// Starting a new login, even one that fails, forgets the user waiting to give a code.
+	code, _, _ = login("wrongpa55word")
+	if code != http.StatusOK {
+		t.Fatalf("wrong password: want %d; got %d", http.StatusOK, code)
+	}
+	totpCode, err := totp.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, header, _ = ts.submitForm(t, "/user/login", "/user/login/two-factor", url.Values{"code": {totpCode}})
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Fatalf("code after new login: want %d to /user/login; got %d to %q", http.StatusSeeOther, code,
+			header.Get("Location"))
+	}
+	code, _, _ = ts.get(t, "/user/profile")
+	if code != http.StatusSeeOther {
+		t.Errorf("profile: want %d; got %d", http.StatusSeeOther, code)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/rlr524/snippetbox/pkg/forms"
+	"github.com/rlr524/snippetbox/pkg/models"
+	"net/http"
+	"time"
+)
+
+// The snippetUnlockKey function returns the session key which records that a password protected snippet has been
+// unlocked. Each snippet has its own key, so unlocking one snippet doesn't unlock any others.
+func snippetUnlockKey(s *models.Snippet) string {
+	return "unlockedSnippet:" + s.Slug
+}
+
+// The snippetPasswordFingerprint function returns a short fingerprint of a snippet's password hash, which is
+// stored in the session when the snippet is unlocked. Changing the password changes the fingerprint, so everyone
+// who unlocked the snippet with the old password has to unlock it again.
+func snippetPasswordFingerprint(s *models.Snippet) string {
+	sum := sha256.Sum256(s.HashedPassword)
+	return hex.EncodeToString(sum[:8])
+}
+
+// The snippetLocked helper returns true if the snippet has a password and the current user hasn't unlocked it.
+// The author of a snippet never needs its password.
+func (app *Application) snippetLocked(r *http.Request, s *models.Snippet) bool {
+	if !s.Protected() || s.UserID == app.authenticatedUserID(r) {
+		return false
+	}
+	return app.session.GetString(r, snippetUnlockKey(s)) != snippetPasswordFingerprint(s)
+}
+
+// unlockSnippet function checks the password given for a password protected snippet and, if it's right,
+// remembers in the session that the snippet is unlocked. Wrong passwords are limited in the same way as failed
+// logins, both for the client and for the snippet.
+func (app *Application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.GetBySlug(chi.URLParam(r, "slug"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if !app.snippetLocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("password")
+	if !form.Valid() {
+		app.render(w, r, "unlock.page.gohtml", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	ipKey, snippetKey := "ip:"+clientIP(r), "snippet:"+s.Slug
+	failures, wait, err := app.loginLimiter.Attempt(ipKey, snippetKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.infoLog.Printf("Unlock attempt for snippet %d from %s refused, retry in %s", s.ID, clientIP(r),
+			wait.Round(time.Second))
+		form.FormErrors.Add("generic", fmt.Sprintf("Too many failed attempts, please try again in %s",
+			humanDuration(wait)))
+		app.render(w, r, "unlock.page.gohtml", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	if !s.MatchesPassword(form.Get("password")) {
+		app.infoLog.Printf("Failed unlock for snippet %d from %s (%d failures)", s.ID, clientIP(r), failures)
+		form.FormErrors.Add("generic", "This password is incorrect")
+		app.render(w, r, "unlock.page.gohtml", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	err = app.loginLimiter.Release(ipKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.loginLimiter.Reset(snippetKey)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, snippetUnlockKey(s), snippetPasswordFingerprint(s))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s", s.Slug), http.StatusSeeOther)
+}
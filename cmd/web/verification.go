@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email verification links expire after a day.
+const verificationTTL = 24 * time.Hour
+
+var errInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// The newVerificationToken method returns a token for the verification link emailed to a new user. Nothing is
+// stored in the database: the token holds the user's ID and the time it expires, signed with an HMAC-SHA256 of
+// the application secret so it can't be forged or altered.
+func (app *Application) newVerificationToken(userID int, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userID, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(app.signVerification(payload))
+}
+
+// The parseVerificationToken method checks the signature and expiry of a token from newVerificationToken and
+// returns the ID of the user it was issued to.
+func (app *Application) parseVerificationToken(token string) (int, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, errInvalidVerificationToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, errInvalidVerificationToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return 0, errInvalidVerificationToken
+	}
+	if !hmac.Equal(signature, app.signVerification(string(payload))) {
+		return 0, errInvalidVerificationToken
+	}
+
+	id, expires, ok := strings.Cut(string(payload), ".")
+	if !ok {
+		return 0, errInvalidVerificationToken
+	}
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, errInvalidVerificationToken
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresUnix {
+		return 0, errInvalidVerificationToken
+	}
+	return userID, nil
+}
+
+// The signVerification method returns the HMAC of a verification token payload. The payload is prefixed with the
+// purpose of the signature, so a value signed with the same secret for some other reason can't be used as one.
+func (app *Application) signVerification(payload string) []byte {
+	mac := hmac.New(sha256.New, app.secret)
+	mac.Write([]byte("email-verification:" + payload))
+	return mac.Sum(nil)
+}
+
+// The sendVerificationEmail method emails a new verification link to a user who has signed up
+func (app *Application) sendVerificationEmail(userID int, email string) error {
+	token := app.newVerificationToken(userID, time.Now().Add(verificationTTL))
+	link := fmt.Sprintf("%s/user/verify?token=%s", app.baseURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Thanks for signing up to Snippetbox!\n\n"+
+		"To activate your account, open this link within the next %d hours:\n\n%s\n\n"+
+		"If you didn't sign up, you can ignore this email.\n",
+		int(verificationTTL.Hours()), link)
+	return app.mailer.Send(email, "Verify your Snippetbox email address", body)
+}
@@ -0,0 +1,123 @@
+// Package limiter slows down and then locks out repeated failed attempts at something, such as logging in. The
+// failures are counted in a Store, so the in-process MemoryStore can later be replaced with one shared between
+// servers without changing the Limiter.
+package limiter
+
+import (
+	"time"
+)
+
+// Attempts holds the number of failures recorded for a key and the time of the latest one.
+type Attempts struct {
+	Failures int
+	Last     time.Time
+}
+
+// The Store interface is implemented by anything that can count failures for a key. A store should forget a
+// key's failures once they are older than the Limiter's Lockout.
+type Store interface {
+	// Reserve records an attempt for key at the given time as a failure and returns the updated Attempts, unless
+	// penalty says the key's earlier failures still have to be waited out, in which case nothing is recorded and
+	// the remaining wait is returned. Checking and recording must happen together, so that concurrent attempts
+	// can't all be allowed before any of them is counted.
+	Reserve(key string, at time.Time, penalty func(failures int) time.Duration) (Attempts, time.Duration, error)
+	// Release takes back one failure recorded by Reserve, for an attempt that didn't fail.
+	Release(key string) error
+	// Reset forgets the failures recorded for key.
+	Reset(key string) error
+}
+
+// Limiter decides how long a key has to wait before its next attempt. The first Free failures don't cause a
+// wait; after that each failure doubles the wait, starting at Delay, until there have been LockoutAfter failures
+// and the key is locked out for Lockout since its latest failure.
+type Limiter struct {
+	Store        Store
+	Free         int
+	Delay        time.Duration
+	LockoutAfter int
+	Lockout      time.Duration
+
+	// Now returns the time attempts are made at. If it's nil, time.Now is used; tests can set it to a fake clock.
+	Now func() time.Time
+}
+
+// New returns a Limiter using the given store with the default settings: three free attempts, then waits
+// doubling from one second, and a 15 minute lockout after ten failures.
+func New(store Store) *Limiter {
+	return &Limiter{
+		Store:        store,
+		Free:         3,
+		Delay:        time.Second,
+		LockoutAfter: 10,
+		Lockout:      15 * time.Minute,
+	}
+}
+
+// Attempt counts an attempt for each of the keys as a failure before the caller checks it, and returns the
+// highest failure count among them. If any key has to wait, the attempt isn't counted and the wait is returned
+// instead; the caller must refuse the attempt without checking it. An attempt that turns out to succeed is taken
+// back with Release or Reset.
+func (l *Limiter) Attempt(keys ...string) (int, time.Duration, error) {
+	var most int
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+	for i, key := range keys {
+		a, wait, err := l.Store.Reserve(key, now, l.penalty)
+		if err == nil && wait > 0 {
+			// Take back the attempts already counted for the other keys, as this one won't be made.
+			err = l.Release(keys[:i]...)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if wait > 0 {
+			return 0, wait, nil
+		}
+		if a.Failures > most {
+			most = a.Failures
+		}
+	}
+	return most, 0, nil
+}
+
+// Release takes back the attempt counted by Attempt for each of the keys, e.g. when it succeeded but the keys'
+// earlier failures should be kept.
+func (l *Limiter) Release(keys ...string) error {
+	for _, key := range keys {
+		err := l.Store.Release(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failures for each of the keys, e.g. after a successful attempt.
+func (l *Limiter) Reset(keys ...string) error {
+	for _, key := range keys {
+		err := l.Store.Reset(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The penalty method returns how long after the latest failure the next attempt is allowed, for a number of
+// failures.
+func (l *Limiter) penalty(failures int) time.Duration {
+	switch {
+	case failures >= l.LockoutAfter:
+		return l.Lockout
+	case failures <= l.Free:
+		return 0
+	}
+
+	d := l.Delay << (failures - l.Free - 1)
+	if d > l.Lockout {
+		d = l.Lockout
+	}
+	return d
+}
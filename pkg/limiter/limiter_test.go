@@ -0,0 +1,245 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for tests which only moves when it's told to
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// The newTestLimiter function returns a Limiter with the default settings, whose MemoryStore forgets failures
+// after the lockout, and the fake clock it reads the time from.
+func newTestLimiter(t *testing.T) (*Limiter, *MemoryStore, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore(15 * time.Minute)
+	l := New(store)
+	l.Now = clock.now
+	return l, store, clock
+}
+
+func TestLimiterPenalty(t *testing.T) {
+	l := New(NewMemoryStore(15 * time.Minute))
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{9, 32 * time.Second},
+		{10, 15 * time.Minute},
+		{50, 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := l.penalty(tt.failures); got != tt.want {
+			t.Errorf("penalty(%d): want %s; got %s", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestLimiterAttempt(t *testing.T) {
+	l, _, clock := newTestLimiter(t)
+
+	// Each step advances the clock and then makes an attempt, none of which succeed.
+	tests := []struct {
+		name         string
+		advance      time.Duration
+		wantFailures int
+		wantWait     time.Duration
+	}{
+		{"First free attempt", 0, 1, 0},
+		{"Second free attempt", 0, 2, 0},
+		{"Third free attempt", 0, 3, 0},
+		{"Fourth attempt, after the free ones", 0, 4, 0},
+		{"Too soon after the fourth", 0, 0, time.Second},
+		{"A second later", time.Second, 5, 0},
+		{"Wait doubles", time.Second, 0, time.Second},
+		{"Two seconds later", time.Second, 6, 0},
+		{"Four seconds later", 4 * time.Second, 7, 0},
+		{"Eight seconds later", 8 * time.Second, 8, 0},
+		{"Sixteen seconds later", 16 * time.Second, 9, 0},
+		{"Thirty-two seconds later", 32 * time.Second, 10, 0},
+		{"Locked out", 32 * time.Second, 0, 15*time.Minute - 32*time.Second},
+		{"Still locked out", 14 * time.Minute, 0, 28 * time.Second},
+		{"After the lockout", 28 * time.Second, 11, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.advance(tt.advance)
+
+			failures, wait, err := l.Attempt("key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failures != tt.wantFailures {
+				t.Errorf("want %d failures; got %d", tt.wantFailures, failures)
+			}
+			if wait != tt.wantWait {
+				t.Errorf("want wait %s; got %s", tt.wantWait, wait)
+			}
+		})
+	}
+}
+
+func TestLimiterAttemptConcurrent(t *testing.T) {
+	l, store, _ := newTestLimiter(t)
+
+	// Every attempt is made at the same moment, so only the free attempts and the first one after them can be
+	// allowed, however the goroutines are scheduled.
+	const attempts = 50
+	start := make(chan struct{})
+	allowed := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			failures, wait, err := l.Attempt("ip", "email")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 {
+				allowed <- failures
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(allowed)
+
+	seen := map[int]bool{}
+	for failures := range allowed {
+		seen[failures] = true
+	}
+	if len(seen) != 4 || !seen[1] || !seen[2] || !seen[3] || !seen[4] {
+		t.Errorf("want attempts with 1 to 4 failures allowed; got %v", seen)
+	}
+
+	// A refused attempt isn't counted against either key.
+	for _, key := range []string{"ip", "email"} {
+		if got := store.attempts[key].Failures; got != 4 {
+			t.Errorf("%s: want 4 failures; got %d", key, got)
+		}
+	}
+}
+
+func TestLimiterAttemptReleasesEarlierKeys(t *testing.T) {
+	l, store, _ := newTestLimiter(t)
+
+	for i := 0; i < 4; i++ {
+		_, _, err := l.Attempt("email")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The email address has to wait, so the attempt already counted for the IP address is taken back.
+	_, wait, err := l.Attempt("ip", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != time.Second {
+		t.Errorf("want wait %s; got %s", time.Second, wait)
+	}
+	if a, ok := store.attempts["ip"]; ok {
+		t.Errorf("ip: want no failures; got %d", a.Failures)
+	}
+}
+
+func TestLimiterReleaseAndReset(t *testing.T) {
+	tests := []struct {
+		name         string
+		finish       func(l *Limiter, key string) error
+		wantFailures int // For the next attempt
+	}{
+		{"Release", func(l *Limiter, key string) error { return l.Release(key) }, 3},
+		{"Reset", func(l *Limiter, key string) error { return l.Reset(key) }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _, _ := newTestLimiter(t)
+
+			// Two failures, then an attempt which succeeds.
+			for i := 0; i < 3; i++ {
+				_, _, err := l.Attempt("key")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := tt.finish(l, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			failures, wait, err := l.Attempt("key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failures != tt.wantFailures || wait != 0 {
+				t.Errorf("want %d failures and no wait; got %d and %s", tt.wantFailures, failures, wait)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	l, store, clock := newTestLimiter(t)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := l.Attempt("old")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failures are kept for Expiry after the latest one.
+	clock.advance(15 * time.Minute)
+	failures, _, err := l.Attempt("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 4 {
+		t.Errorf("at expiry: want 4 failures; got %d", failures)
+	}
+
+	// After that they're forgotten, both when the key is used again and by the sweep of other keys.
+	clock.advance(15*time.Minute + time.Second)
+	_, _, err = l.Attempt("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.attempts["old"]; ok {
+		t.Error("want expired key to be swept")
+	}
+
+	failures, _, err = l.Attempt("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 1 {
+		t.Errorf("after expiry: want 1 failure; got %d", failures)
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps the failures in process memory. The counts are lost when the server stops
+// and aren't shared between servers.
+type MemoryStore struct {
+	// Expiry is how long a key's failures are kept after the latest one. It should be at least the
+	// Limiter's Lockout.
+	Expiry time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]Attempts
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore that forgets failures after expiry.
+func NewMemoryStore(expiry time.Duration) *MemoryStore {
+	return &MemoryStore{
+		Expiry:   expiry,
+		attempts: make(map[string]Attempts),
+	}
+}
+
+// Reserve records an attempt for key at the given time as a failure and returns the updated Attempts, unless the
+// key still has to wait for the penalty after its latest failure, in which case the wait is returned.
+func (s *MemoryStore) Reserve(key string, at time.Time,
+	penalty func(failures int) time.Duration) (Attempts, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(at)
+
+	a := s.attempts[key]
+	if at.Sub(a.Last) > s.Expiry {
+		a = Attempts{}
+	}
+	if wait := a.Last.Add(penalty(a.Failures)).Sub(at); wait > 0 {
+		return a, wait, nil
+	}
+	a.Failures++
+	a.Last = at
+	s.attempts[key] = a
+	return a, 0, nil
+}
+
+// Release takes back one failure recorded for key by Reserve.
+func (s *MemoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	a.Failures--
+	if a.Failures <= 0 {
+		delete(s.attempts, key)
+	} else {
+		s.attempts[key] = a
+	}
+	return nil
+}
+
+// Reset forgets the failures recorded for key.
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// The sweep method removes expired keys, at most once per Expiry, so that the map doesn't keep growing with
+// keys that are never used again. The caller must hold the lock.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.Expiry {
+		return
+	}
+	for key, a := range s.attempts {
+		if now.Sub(a.Last) > s.Expiry {
+			delete(s.attempts, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,78 @@
+// Package mailer sends the emails the application needs, such as password reset links. The Mailer interface lets
+// the SMTP implementation be swapped for the file implementation in development and tests.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The Mailer interface is implemented by anything that can send a plain-text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	Addr     string // host:port of the SMTP server
+	Username string // Optional, PLAIN authentication is used if set
+	Password string
+	From     string
+}
+
+// Send sends an email to a single recipient through the SMTP server.
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, message(m.From, to, subject, body))
+}
+
+// FileMailer is a stand-in for an SMTP server which writes each email to a .eml file in a directory instead of
+// sending it. It's meant for development and tests, where the emails can be read from the directory.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	count uint64
+}
+
+// Send writes the email to a new file in the mailer's directory, creating the directory if needed.
+func (m *FileMailer) Send(to, subject, body string) error {
+	err := os.MkdirAll(m.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	// Name the file with the time and a counter, so that emails sort in the order they were sent.
+	n := atomic.AddUint64(&m.count, 1)
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().UTC().Format("20060102T150405.000000000"), n)
+	return os.WriteFile(filepath.Join(m.Dir, name), message(m.From, to, subject, body), 0o600)
+}
+
+// The message function formats a plain-text email, including its headers, as it's sent over SMTP.
+func message(from, to, subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,264 @@
+// Package migrate applies versioned SQL migrations to a database and keeps track of which have been applied in
+// a schema_migrations table. It's used with the migrations embedded in each storage backend's package.
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Migration is one version of the schema. It's loaded from a pair of files named for example
+// "0001_create_users.up.sql" and "0001_create_users.down.sql", where the number is the version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// A Status reports whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// The filename of a migration is the version, a name and the direction.
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Statements in a migration file are separated by a semicolon at the end of a line. Not every driver can execute
+// several statements at once (the MySQL driver can't by default), so they're run one at a time.
+var statementRX = regexp.MustCompile(`;\s*(\n|$)`)
+
+// Load reads the migrations from the root of fsys and returns them ordered by version. Every migration must
+// have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		m := filenameRX.FindStringSubmatch(path.Base(file))
+		if m == nil {
+			return nil, fmt.Errorf("migrate: invalid migration filename %q", file)
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies a set of migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New loads the migrations from fsys and returns a Migrator for them.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies every migration which hasn't been applied yet, in order, and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, mig := range m.Migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		err = m.run(mig.Up, fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", mig.Version))
+		if err != nil {
+			return done, fmt.Errorf("migrate: applying version %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration and returns it, or nil if no migrations are applied.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		mig := m.Migrations[i]
+		if !applied[mig.Version] {
+			continue
+		}
+		err = m.run(mig.Down, fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %d", mig.Version))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: rolling back version %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		return &mig, nil
+	}
+	return nil, nil
+}
+
+// Baseline records every migration up to and including version as applied, without running it, and returns the
+// ones it recorded. It's for databases whose schema was set up before the migrations were, so that Up only
+// applies the migrations after the ones already reflected in the schema.
+func (m *Migrator) Baseline(version int) ([]Migration, error) {
+	known := false
+	for _, mig := range m.Migrations {
+		if mig.Version == version {
+			known = true
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("migrate: no migration has version %d", version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	done := []Migration{}
+	for _, mig := range m.Migrations {
+		if mig.Version > version || applied[mig.Version] {
+			continue
+		}
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO schema_migrations (version) VALUES (%d)", mig.Version))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: recording version %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// Status reports whether each migration has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		statuses = append(statuses, Status{Migration: mig, Applied: applied[mig.Version]})
+	}
+	return statuses, nil
+}
+
+// The applied method creates the schema_migrations table if needed and returns the set of applied versions.
+func (m *Migrator) applied() (map[int]bool, error) {
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// The run method executes the statements of a migration followed by the statement which records it, in a single
+// transaction. Note that MySQL implicitly commits after most schema changes, so there a failed migration may be
+// left partially applied.
+func (m *Migrator) run(script, record string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Calling Rollback after Commit is a no-op, so this is safe to defer.
+	defer tx.Rollback()
+
+	for _, stmt := range statementRX.Split(script, -1) {
+		if isEmpty(stmt) {
+			continue
+		}
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(record); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// The isEmpty function reports whether a statement contains nothing but whitespace and "--" comments.
+func isEmpty(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
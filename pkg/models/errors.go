@@ -8,4 +8,10 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrInvalidToken = errors.New("models: invalid or expired token")
+
+	ErrInactiveAccount = errors.New("models: account deactivated")
+
+	ErrUnverifiedAccount = errors.New("models: email address not verified")
 )
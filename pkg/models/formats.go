@@ -0,0 +1,12 @@
+package models
+
+// How a snippet's content is shown. Plain text is shown as it is, code is highlighted for the snippet's language
+// and markdown is rendered as HTML.
+const (
+	FormatPlain    = "plain"
+	FormatCode     = "code"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists every format a snippet can have.
+var Formats = []string{FormatPlain, FormatCode, FormatMarkdown}
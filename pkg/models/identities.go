@@ -0,0 +1,19 @@
+package models
+
+import (
+	"crypto/rand"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RandomPasswordHash returns the bcrypt hash of a random password nobody knows. It's used for users created by
+// single sign-on, who don't have a password of their own until they set one with a password reset.
+func RandomPasswordHash() ([]byte, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	// bcrypt only uses the first 72 bytes of a password, so the raw random bytes are fine.
+	return bcrypt.GenerateFromPassword(b, 12)
+}
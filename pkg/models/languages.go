@@ -0,0 +1,34 @@
+package models
+
+// LanguagePlainText is the language of a snippet which isn't highlighted.
+const LanguagePlainText = "plaintext"
+
+// Languages lists the languages a snippet can be highlighted as, by the name of the syntax highlighting lexer.
+var Languages = []string{
+	LanguagePlainText,
+	"bash",
+	"c",
+	"cpp",
+	"csharp",
+	"css",
+	"diff",
+	"docker",
+	"go",
+	"html",
+	"ini",
+	"java",
+	"javascript",
+	"json",
+	"kotlin",
+	"markdown",
+	"php",
+	"python",
+	"ruby",
+	"rust",
+	"sql",
+	"swift",
+	"toml",
+	"typescript",
+	"xml",
+	"yaml",
+}
@@ -0,0 +1,83 @@
+// Package memory implements the storage interfaces from the models package in memory. It follows the same rules
+// as the MySQL backend, which makes it suitable for testing handlers and for running the application without a
+// database server. All data is lost when the process exits.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// DB holds the in-memory tables shared by the models in this package. It plays the same role as the sql.DB
+// connection pool does for the MySQL models, and all access is guarded by its mutex so it is safe for
+// concurrent use by multiple goroutines.
+type DB struct {
+	mu sync.RWMutex
+
+	snippets map[int]*models.Snippet
+	// slugs maps the slug of each snippet to its ID, like the unique index on the snippets table.
+	slugs map[string]int
+	// revisions holds the revisions of each snippet, oldest first, keyed by snippet ID.
+	revisions map[int][]*models.Revision
+	users     map[int]*models.User
+	tokens    map[int]*token
+	resets    map[string]*passwordReset
+	// twoFactor holds the two-factor settings of the users who have enabled it, keyed by user ID.
+	twoFactor map[int]*twoFactor
+	// identities maps an OpenID Connect issuer and subject, joined by oidcKey, to the ID of the linked user.
+	identities map[string]int
+
+	nextSnippetID  int
+	nextRevisionID int
+	nextUserID     int
+	nextTokenID    int
+}
+
+// The token type is a row of the tokens table, which includes the hash of the token.
+type token struct {
+	models.Token
+	hash []byte
+}
+
+// The passwordReset type is a row of the password_resets table, which is keyed by the hash of the token.
+type passwordReset struct {
+	userID  int
+	expires time.Time
+}
+
+// The twoFactor type holds the totp_secret and totp_counter columns of a user, and the hashes of their recovery
+// codes.
+type twoFactor struct {
+	secret        string
+	counter       int64
+	recoveryCodes map[string]bool
+}
+
+// NewDB returns a new, empty in-memory database.
+func NewDB() *DB {
+	return &DB{
+		snippets:   map[int]*models.Snippet{},
+		slugs:      map[string]int{},
+		revisions:  map[int][]*models.Revision{},
+		users:      map[int]*models.User{},
+		tokens:     map[int]*token{},
+		resets:     map[string]*passwordReset{},
+		twoFactor:  map[int]*twoFactor{},
+		identities: map[string]int{},
+	}
+}
+
+// The now function returns the current UTC time truncated to the second, matching the precision of the DATETIME
+// columns used by the MySQL backend.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Check at compile time that the models in this package implement the storage interfaces.
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
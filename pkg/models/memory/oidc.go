@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// The oidcKey function returns the key of an identity in the identities map. Issuers are URLs, which can't
+// contain a NUL byte, so the key is unambiguous.
+func oidcKey(issuer, subject string) string {
+	return issuer + "\x00" + subject
+}
+
+// AuthenticateOIDC function returns the ID of the user who has signed in through an OpenID Connect provider. A
+// user already linked to the provider's issuer and subject is returned directly. Otherwise the user with the
+// same email address is linked, or a new active user is created if there isn't one, so the provider must have
+// verified the email. An unverified user with the email address is taken over by the provider's user. If the
+// user has been deactivated, return the models.ErrInactiveAccount error.
+func (m *UserModel) AuthenticateOIDC(issuer, subject, name, email string) (int, error) {
+	// The bcrypt hash is slow, so it's made without holding the lock even though it's only needed for a new or
+	// claimed user.
+	hashedPassword, err := models.RandomPasswordHash()
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u := m.DB.users[m.DB.identities[oidcKey(issuer, subject)]]
+	if u == nil {
+		u = m.DB.userByEmail(email)
+		if u != nil && !u.Verified {
+			// Whoever signed up with the address never proved they own it, so the provider's user claims the
+			// account, and the password set at signup stops working.
+			u.Name = name
+			u.HashedPassword = hashedPassword
+			u.Active = true
+			u.Verified = true
+		}
+	}
+	if u == nil {
+		m.DB.nextUserID++
+		u = &models.User{
+			ID:             m.DB.nextUserID,
+			Name:           name,
+			Email:          email,
+			HashedPassword: hashedPassword,
+			Created:        now(),
+			Active:         true,
+			Verified:       true,
+			Role:           models.RoleUser,
+		}
+		m.DB.users[u.ID] = u
+	}
+	if !u.Active {
+		return 0, models.ErrInactiveAccount
+	}
+
+	m.DB.identities[oidcKey(issuer, subject)] = u.ID
+	return u.ID, nil
+}
@@ -0,0 +1,306 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// SnippetModel type which wraps the in-memory DB
+type SnippetModel struct {
+	DB *DB
+}
+
+// Insert function inserts a new snippet, owned by the user with the given userID, and returns the random slug it
+// was given. If password isn't empty, the snippet can only be read with it.
+func (m *SnippetModel) Insert(title, content, format, language, expires, visibility, password string,
+	userID int) (string, error) {
+	days, err := strconv.Atoi(expires)
+	if err != nil {
+		return "", err
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	// Hash the password before taking the lock, as bcrypt is deliberately slow.
+	hashedPassword, err := models.HashSnippetPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Like the foreign key on the snippets table, the owner has to be an existing user.
+	if _, ok := m.DB.users[userID]; !ok {
+		return "", models.ErrNoRecord
+	}
+	if _, ok := m.DB.slugs[slug]; ok {
+		return "", errors.New("memory: duplicate snippet slug")
+	}
+
+	m.DB.nextSnippetID++
+	created := now()
+	m.DB.slugs[slug] = m.DB.nextSnippetID
+	m.DB.snippets[m.DB.nextSnippetID] = &models.Snippet{
+		ID:             m.DB.nextSnippetID,
+		Slug:           slug,
+		UserID:         userID,
+		Title:          title,
+		Content:        content,
+		Format:         format,
+		Language:       language,
+		Created:        created,
+		Expires:        created.AddDate(0, 0, days),
+		Visibility:     visibility,
+		HashedPassword: hashedPassword,
+	}
+	m.DB.addRevision(m.DB.snippets[m.DB.nextSnippetID], created)
+	return slug, nil
+}
+
+// Get function returns a specific snippet based on its ID. Expired snippets, and private snippets which belong to
+// someone other than the viewer, are treated as if they don't exist.
+func (m *SnippetModel) Get(id, viewerID int) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.Expires.After(now()) || !s.CanView(viewerID) {
+		return nil, models.ErrNoRecord
+	}
+	return m.DB.snippet(s), nil
+}
+
+// GetBySlug function returns a specific snippet based on the slug in its URL, with the same rules as Get
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	id, ok := m.DB.slugs[slug]
+	m.DB.mu.RUnlock()
+
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return m.Get(id, viewerID)
+}
+
+// Latest function returns the 20 most recently created public snippets which haven't expired, along with the
+// viewer's own snippets
+func (m *SnippetModel) Latest(viewerID int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	snippets := []*models.Snippet{}
+	for _, s := range m.DB.snippets {
+		if s.Expires.After(t) && (s.Visibility == models.VisibilityPublic || s.UserID == viewerID) {
+			snippets = append(snippets, m.DB.snippet(s))
+		}
+	}
+
+	// Order by created descending, using the ID to keep the order stable for snippets created in the same second.
+	sort.Slice(snippets, func(i, j int) bool {
+		if snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+	if len(snippets) > 20 {
+		snippets = snippets[:20]
+	}
+	return snippets, nil
+}
+
+// Update function replaces the title, content, format, language and visibility of a specific snippet based on its
+// ID, and its password unless password is nil. If the title or content changed, a new revision is saved.
+func (m *SnippetModel) Update(id int, title, content, format, language, visibility string, password *string) error {
+	// The bcrypt hash is slow, so it's made without holding the lock.
+	var hashedPassword []byte
+	if password != nil {
+		var err error
+		hashedPassword, err = models.HashSnippetPassword(*password)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	changed := title != s.Title || content != s.Content
+	s.Title = title
+	s.Content = content
+	s.Format = format
+	s.Language = language
+	s.Visibility = visibility
+	if password != nil {
+		s.HashedPassword = hashedPassword
+	}
+	if changed {
+		m.DB.addRevision(s, now())
+	}
+	return nil
+}
+
+// Fork function copies a specific snippet, based on its ID, to a new snippet owned by the user with the given
+// userID, and returns the random slug the copy was given. The copy keeps the visibility and password of the
+// original, records it as its parent and expires after the given number of days.
+func (m *SnippetModel) Fork(id, userID int, expires string) (string, error) {
+	days, err := strconv.Atoi(expires)
+	if err != nil {
+		return "", err
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	parent, ok := m.DB.snippets[id]
+	if !ok {
+		return "", models.ErrNoRecord
+	}
+	if _, ok := m.DB.users[userID]; !ok {
+		return "", models.ErrNoRecord
+	}
+	if _, ok := m.DB.slugs[slug]; ok {
+		return "", errors.New("memory: duplicate snippet slug")
+	}
+
+	m.DB.nextSnippetID++
+	created := now()
+	m.DB.slugs[slug] = m.DB.nextSnippetID
+	m.DB.snippets[m.DB.nextSnippetID] = &models.Snippet{
+		ID:             m.DB.nextSnippetID,
+		Slug:           slug,
+		UserID:         userID,
+		Title:          parent.Title,
+		Content:        parent.Content,
+		Format:         parent.Format,
+		Language:       parent.Language,
+		Created:        created,
+		Expires:        created.AddDate(0, 0, days),
+		Visibility:     parent.Visibility,
+		HashedPassword: parent.HashedPassword,
+		ParentID:       parent.ID,
+	}
+	m.DB.addRevision(m.DB.snippets[m.DB.nextSnippetID], created)
+	return slug, nil
+}
+
+// Delete function removes a specific snippet based on its ID
+func (m *SnippetModel) Delete(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	return m.DB.deleteSnippet(id)
+}
+
+// DeleteBySlug function removes a specific snippet based on its slug. Unlike GetBySlug it doesn't check whether
+// the snippet can be seen, so moderators can remove any snippet, including private and expired ones.
+func (m *SnippetModel) DeleteBySlug(slug string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	id, ok := m.DB.slugs[slug]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	return m.DB.deleteSnippet(id)
+}
+
+// The deleteSnippet method removes the snippet with the given ID and its revisions. The caller must hold the
+// write lock.
+func (db *DB) deleteSnippet(id int) error {
+	s, ok := db.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	delete(db.slugs, s.Slug)
+	delete(db.revisions, id)
+	delete(db.snippets, id)
+	// Like ON DELETE SET NULL on the parent_id column, forks of the snippet no longer have a parent.
+	for _, f := range db.snippets {
+		if f.ParentID == id {
+			f.ParentID = 0
+		}
+	}
+	return nil
+}
+
+// Revisions function returns every revision of a specific snippet based on its ID, newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	stored := m.DB.revisions[id]
+	revisions := make([]*models.Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.DB.revision(stored[i]))
+	}
+	return revisions, nil
+}
+
+// Revision function returns a specific revision of a specific snippet based on their IDs
+func (m *SnippetModel) Revision(id, revisionID int) (*models.Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, r := range m.DB.revisions[id] {
+		if r.ID == revisionID {
+			return m.DB.revision(r), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// The addRevision method saves the current title and content of a stored snippet as a new revision, like the
+// snippet_revisions table. The caller must hold the lock.
+func (db *DB) addRevision(s *models.Snippet, created time.Time) {
+	db.nextRevisionID++
+	db.revisions[s.ID] = append(db.revisions[s.ID], &models.Revision{
+		ID:        db.nextRevisionID,
+		SnippetID: s.ID,
+		UserID:    s.UserID,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   created,
+	})
+}
+
+// The revision method returns a copy of a stored revision with the author's name filled in. The caller must hold
+// the lock.
+func (db *DB) revision(r *models.Revision) *models.Revision {
+	c := *r
+	if u, ok := db.users[r.UserID]; ok {
+		c.Author = u.Name
+	}
+	return &c
+}
+
+// The snippet method returns a copy of a stored snippet with the author's name and number of forks filled in, so
+// that callers can't modify the stored snippet. Like the SQL backends, only unexpired forks that aren't private
+// are counted. The caller must hold the lock.
+func (db *DB) snippet(s *models.Snippet) *models.Snippet {
+	c := *s
+	if u, ok := db.users[s.UserID]; ok {
+		c.Author = u.Name
+	}
+	t := now()
+	for _, f := range db.snippets {
+		if f.ParentID == s.ID && f.Expires.After(t) && f.Visibility != models.VisibilityPrivate {
+			c.Forks++
+		}
+	}
+	return &c
+}
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// The newTestDB function returns a DB holding two active users, Alice with ID 1 and Bob with ID 2. They're added
+// directly rather than through UserModel.Insert, so the tests don't wait for bcrypt.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db := NewDB()
+	for _, name := range []string{"Alice", "Bob"} {
+		db.nextUserID++
+		db.users[db.nextUserID] = &models.User{
+			ID:       db.nextUserID,
+			Name:     name,
+			Created:  now(),
+			Active:   true,
+			Verified: true,
+			Role:     models.RoleUser,
+		}
+	}
+	return db
+}
+
+// The insertSnippet function adds a snippet owned by userID with the given visibility and returns its slug. If
+// expired is true, the snippet's expiry is moved into the past.
+func insertSnippet(t *testing.T, db *DB, title, visibility string, userID int, expired bool) string {
+	t.Helper()
+
+	m := &SnippetModel{DB: db}
+	slug, err := m.Insert(title, "content", models.FormatCode, "plaintext", "7", visibility, "", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired {
+		db.mu.Lock()
+		db.snippets[db.slugs[slug]].Expires = now().Add(-time.Second)
+		db.mu.Unlock()
+	}
+	return slug
+}
+
+func TestSnippetModelGetBySlug(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	public := insertSnippet(t, db, "Public", models.VisibilityPublic, 1, false)
+	unlisted := insertSnippet(t, db, "Unlisted", models.VisibilityUnlisted, 1, false)
+	private := insertSnippet(t, db, "Private", models.VisibilityPrivate, 1, false)
+	expired := insertSnippet(t, db, "Expired", models.VisibilityPublic, 1, true)
+	expiredPrivate := insertSnippet(t, db, "Expired private", models.VisibilityPrivate, 1, true)
+
+	tests := []struct {
+		name      string
+		slug      string
+		viewerID  int
+		wantTitle string
+		wantErr   error
+	}{
+		{"Public, anonymous", public, 0, "Public", nil},
+		{"Public, other user", public, 2, "Public", nil},
+		{"Unlisted, anonymous", unlisted, 0, "Unlisted", nil},
+		{"Unlisted, other user", unlisted, 2, "Unlisted", nil},
+		{"Private, owner", private, 1, "Private", nil},
+		{"Private, other user", private, 2, "", models.ErrNoRecord},
+		{"Private, anonymous", private, 0, "", models.ErrNoRecord},
+		{"Expired, owner", expired, 1, "", models.ErrNoRecord},
+		{"Expired, anonymous", expired, 0, "", models.ErrNoRecord},
+		{"Expired private, owner", expiredPrivate, 1, "", models.ErrNoRecord},
+		{"Unknown slug", "AAAAAAAAAAAA", 1, "", models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.GetBySlug(tt.slug, tt.viewerID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if s.Title != tt.wantTitle {
+				t.Errorf("want title %q; got %q", tt.wantTitle, s.Title)
+			}
+			if s.Author != "Alice" {
+				t.Errorf("want author %q; got %q", "Alice", s.Author)
+			}
+		})
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	insertSnippet(t, db, "Alice public", models.VisibilityPublic, 1, false)
+	insertSnippet(t, db, "Alice unlisted", models.VisibilityUnlisted, 1, false)
+	insertSnippet(t, db, "Alice private", models.VisibilityPrivate, 1, false)
+	insertSnippet(t, db, "Alice expired", models.VisibilityPublic, 1, true)
+	insertSnippet(t, db, "Bob public", models.VisibilityPublic, 2, false)
+	insertSnippet(t, db, "Bob private", models.VisibilityPrivate, 2, false)
+	insertSnippet(t, db, "Bob expired private", models.VisibilityPrivate, 2, true)
+
+	// Snippets created in the same second are returned newest ID first.
+	tests := []struct {
+		name       string
+		viewerID   int
+		wantTitles []string
+	}{
+		{"Anonymous", 0, []string{"Bob public", "Alice public"}},
+		{"Alice", 1, []string{"Bob public", "Alice private", "Alice unlisted", "Alice public"}},
+		{"Bob", 2, []string{"Bob private", "Bob public", "Alice public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Latest(tt.viewerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, s := range snippets {
+				titles = append(titles, s.Title)
+			}
+			if len(titles) != len(tt.wantTitles) {
+				t.Fatalf("want %q; got %q", tt.wantTitles, titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.wantTitles[i] {
+					t.Fatalf("want %q; got %q", tt.wantTitles, titles)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetModelUpdatePassword(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	none, secret, other := "", "s3cret", "0ther"
+
+	tests := []struct {
+		name     string
+		password *string
+		want     string // The password the snippet has afterwards, or "" for none
+	}{
+		{"Set", &secret, "s3cret"},
+		{"Left as it is", nil, "s3cret"},
+		{"Replaced", &other, "0ther"},
+		{"Removed", &none, ""},
+		{"Left without one", nil, ""},
+	}
+
+	slug := insertSnippet(t, db, "Title", models.VisibilityPublic, 1, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.GetBySlug(slug, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.Update(s.ID, s.Title, s.Content, s.Format, s.Language, s.Visibility, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = m.GetBySlug(slug, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if s.Protected() {
+					t.Error("want no password")
+				}
+			} else if !s.MatchesPassword(tt.want) {
+				t.Errorf("want password %q", tt.want)
+			}
+		})
+	}
+}
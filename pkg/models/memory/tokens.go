@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"crypto/subtle"
+	"sort"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// TokenModel type which wraps the in-memory DB
+type TokenModel struct {
+	DB *DB
+}
+
+// Insert function issues a new token for a user with the given scopes, returning the plain-text token
+func (m *TokenModel) Insert(userID int, name string, scopes []string) (string, error) {
+	plaintext, hash, err := models.NewTokenPlaintext()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.users[userID]; !ok {
+		return "", models.ErrNoRecord
+	}
+
+	m.DB.nextTokenID++
+	m.DB.tokens[m.DB.nextTokenID] = &token{
+		Token: models.Token{
+			ID:      m.DB.nextTokenID,
+			UserID:  userID,
+			Name:    name,
+			Scopes:  append([]string{}, scopes...),
+			Created: now(),
+		},
+		hash: hash,
+	}
+	return plaintext, nil
+}
+
+// Authenticate function returns the token matching a plain-text token. If there is no matching token, or the user
+// it belongs to is not active, return the ErrInvalidCredentials error.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	hash := models.HashToken(plaintext)
+
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, t := range m.DB.tokens {
+		if subtle.ConstantTimeCompare(t.hash, hash) == 1 {
+			if u, ok := m.DB.users[t.UserID]; !ok || !u.Active {
+				break
+			}
+			return copyToken(t), nil
+		}
+	}
+	return nil, models.ErrInvalidCredentials
+}
+
+// List function returns all the tokens belonging to a user, newest first
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, copyToken(t))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// Revoke function deletes one of a user's tokens. If no token with the ID belongs to the user, return
+// models.ErrNoRecord.
+func (m *TokenModel) Revoke(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+	delete(m.DB.tokens, id)
+	return nil
+}
+
+// The copyToken function returns a copy of a stored token that the caller is free to modify.
+func copyToken(t *token) *models.Token {
+	c := t.Token
+	c.Scopes = append([]string{}, t.Scopes...)
+	return &c
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// TOTPSecret function returns the secret used to check the two-factor codes of a user, or an empty string if
+// they haven't enabled two-factor login. If there is no such user, return the models.ErrNoRecord error.
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	if _, ok := m.DB.users[id]; !ok {
+		return "", models.ErrNoRecord
+	}
+	if tf := m.DB.twoFactor[id]; tf != nil {
+		return tf.secret, nil
+	}
+	return "", nil
+}
+
+// EnableTwoFactor function turns on two-factor login for a user with a confirmed TOTP secret. The recovery codes
+// replace any the user had before, and only their hashes are stored.
+func (m *UserModel) EnableTwoFactor(id int, secret string, recoveryCodes []string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tf := &twoFactor{secret: secret, recoveryCodes: map[string]bool{}}
+	for _, code := range recoveryCodes {
+		tf.recoveryCodes[string(models.HashRecoveryCode(code))] = true
+	}
+	m.DB.twoFactor[id] = tf
+	return nil
+}
+
+// DisableTwoFactor function turns off two-factor login for a user and removes their recovery codes
+func (m *UserModel) DisableTwoFactor(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	delete(m.DB.twoFactor, id)
+	return nil
+}
+
+// UseTOTPCounter function records that a two-factor code for the given TOTP time step has been accepted. Codes
+// for the same or an earlier time step can't be used again, so if one already has been, return the
+// models.ErrInvalidToken error.
+func (m *UserModel) UseTOTPCounter(id int, counter int64) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tf := m.DB.twoFactor[id]
+	if tf == nil || tf.counter >= counter {
+		return models.ErrInvalidToken
+	}
+	tf.counter = counter
+	return nil
+}
+
+// UseRecoveryCode function deletes one of a user's recovery codes, so it can only be used once. If the user has
+// no such code, return the models.ErrInvalidToken error.
+func (m *UserModel) UseRecoveryCode(id int, code string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tf := m.DB.twoFactor[id]
+	hash := string(models.HashRecoveryCode(code))
+	if tf == nil || !tf.recoveryCodes[hash] {
+		return models.ErrInvalidToken
+	}
+	delete(tf.recoveryCodes, hash)
+	return nil
+}
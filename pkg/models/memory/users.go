@@ -0,0 +1,289 @@
+package memory
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel type which wraps the in-memory DB
+type UserModel struct {
+	DB *DB
+}
+
+// Insert function creates a new user with a bcrypt hash of the password and returns their ID. The user stays
+// inactive until their email address has been verified. Email addresses are compared case-insensitively, as
+// they are by the users_uc_email constraint in MySQL, and a duplicate returns the ErrDuplicateEmail error.
+func (m *UserModel) Insert(name, email, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if m.DB.userByEmail(email) != nil {
+		return 0, models.ErrDuplicateEmail
+	}
+
+	m.DB.nextUserID++
+	m.DB.users[m.DB.nextUserID] = &models.User{
+		ID:             m.DB.nextUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        now(),
+		Active:         false,
+		Verified:       false,
+		Role:           models.RoleUser,
+	}
+	return m.DB.nextUserID, nil
+}
+
+// Activate function marks the user with the given ID as verified and active, once they've verified their email
+// address. Only a user who hasn't verified it yet is changed, so a user an admin has deactivated can't turn their
+// account back on with the link. If there is no such unverified user, return the models.ErrNoRecord error.
+func (m *UserModel) Activate(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, ok := m.DB.users[id]
+	if !ok || u.Verified {
+		return models.ErrNoRecord
+	}
+	u.Active = true
+	u.Verified = true
+	return nil
+}
+
+// UnverifiedID function returns the ID of the user with the given email address, if they haven't verified it
+// yet, so that a new verification link can be sent. Otherwise return the models.ErrNoRecord error.
+func (m *UserModel) UnverifiedID(email string) (int, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u := m.DB.userByEmail(email)
+	if u == nil || u.Verified {
+		return 0, models.ErrNoRecord
+	}
+	return u.ID, nil
+}
+
+// Authenticate function returns the ID of the user with the given email and password. If no matching email
+// exists or the password is wrong, return the ErrInvalidCredentials error. If the password is right but the user
+// hasn't verified their email address, return the ErrUnverifiedAccount error, and if they've been deactivated,
+// return the ErrInactiveAccount error.
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.DB.mu.RLock()
+	u := m.DB.userByEmail(email)
+	var id int
+	var hashedPassword []byte
+	var active, verified bool
+	if u != nil {
+		id, hashedPassword, active, verified = u.ID, u.HashedPassword, u.Active, u.Verified
+	}
+	m.DB.mu.RUnlock()
+
+	if id == 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	// The bcrypt comparison is slow, so it's done without holding the lock.
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	// Only once the password is known to be right, tell the caller that the email address hasn't been verified
+	// or that the account has been deactivated.
+	if !verified {
+		return 0, models.ErrUnverifiedAccount
+	}
+	if !active {
+		return 0, models.ErrInactiveAccount
+	}
+	return id, nil
+}
+
+// Exists function reports whether there is an active user with the given ID
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	return ok && u.Active, nil
+}
+
+// Get function returns a specific user based on their ID. Like the SQL backends, the hashed password isn't
+// included.
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	c := *u
+	c.HashedPassword = nil
+	c.TwoFactorEnabled = m.DB.twoFactor[id] != nil
+	return &c, nil
+}
+
+// ChangePassword function replaces the password of a user after checking that currentPassword is their current
+// password, and deletes any password reset tokens issued to them. If it isn't, return the ErrInvalidCredentials
+// error.
+func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
+	m.DB.mu.RLock()
+	u, ok := m.DB.users[id]
+	var currentHashedPassword []byte
+	if ok {
+		currentHashedPassword = u.HashedPassword
+	}
+	m.DB.mu.RUnlock()
+
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	err := bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if u, ok := m.DB.users[id]; ok {
+		u.HashedPassword = newHashedPassword
+	}
+	// A reset link sent before the change mustn't be able to undo it.
+	m.DB.deleteResets(id)
+	return nil
+}
+
+// CreatePasswordReset function issues a password reset token for the active user with the given email, which
+// expires after ttl. If there is no active user with the email, return models.ErrNoRecord.
+func (m *UserModel) CreatePasswordReset(email string, ttl time.Duration) (string, error) {
+	plaintext, hash, err := models.NewTokenPlaintext()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u := m.DB.userByEmail(email)
+	if u == nil || !u.Active {
+		return "", models.ErrNoRecord
+	}
+
+	m.DB.resets[string(hash)] = &passwordReset{userID: u.ID, expires: time.Now().Add(ttl)}
+	return plaintext, nil
+}
+
+// ResetPassword function sets a new password for the user a password reset token was issued to, and deletes all
+// of the user's reset tokens. If the token doesn't exist or has expired, return the models.ErrInvalidToken error.
+func (m *UserModel) ResetPassword(token, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	reset, ok := m.DB.resets[string(models.HashToken(token))]
+	if !ok || !reset.expires.After(time.Now()) {
+		return models.ErrInvalidToken
+	}
+
+	if u, ok := m.DB.users[reset.userID]; ok {
+		u.HashedPassword = hashedPassword
+	}
+	m.DB.deleteResets(reset.userID)
+	return nil
+}
+
+// The deleteResets method deletes the password reset tokens issued to a user. The caller must hold the lock.
+func (db *DB) deleteResets(userID int) {
+	for hash, r := range db.resets {
+		if r.userID == userID {
+			delete(db.resets, hash)
+		}
+	}
+}
+
+// The userByEmail method returns the stored user with the given email, or nil. The caller must hold the lock.
+func (db *DB) userByEmail(email string) *models.User {
+	for _, u := range db.users {
+		if strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+// List function returns every user, in the order they signed up. The hashed passwords aren't included.
+func (m *UserModel) List() ([]*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	users := []*models.User{}
+	for id := 1; id <= m.DB.nextUserID; id++ {
+		u, ok := m.DB.users[id]
+		if !ok {
+			continue
+		}
+		c := *u
+		c.HashedPassword = nil
+		c.TwoFactorEnabled = m.DB.twoFactor[id] != nil
+		users = append(users, &c)
+	}
+	return users, nil
+}
+
+// SetActive function activates or deactivates the user with the given ID. If there is no such user, return the
+// models.ErrNoRecord error.
+func (m *UserModel) SetActive(id int, active bool) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	u.Active = active
+	return nil
+}
+
+// SetRole function changes the role of the user with the given ID. If there is no such user, return the
+// models.ErrNoRecord error.
+func (m *UserModel) SetRole(id int, role string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	u.Role = role
+	return nil
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestUserModelInsertDuplicateEmail(t *testing.T) {
+	m := &UserModel{DB: NewDB()}
+
+	_, err := m.Insert("Alice", "alice@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{"Same email", "alice@example.com", models.ErrDuplicateEmail},
+		{"Upper case", "ALICE@EXAMPLE.COM", models.ErrDuplicateEmail},
+		{"Mixed case", "Alice@Example.com", models.ErrDuplicateEmail},
+		{"Different email", "bob@example.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Insert("Someone", tt.email, "pa55word1234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUserModelChangePasswordDeletesResets(t *testing.T) {
+	m := &UserModel{DB: NewDB()}
+
+	id, err := m.Insert("Alice", "alice@example.com", "oldpa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Activate(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.CreatePasswordReset("alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.ChangePassword(id, "oldpa55word1", "newpa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The reset link sent before the change can't be used to set another password.
+	err = m.ResetPassword(token, "otherpa55word")
+	if !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("want error %v; got %v", models.ErrInvalidToken, err)
+	}
+}
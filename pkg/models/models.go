@@ -6,17 +6,45 @@ import (
 
 type Snippet struct {
 	ID      int
+	Slug    string // Random identifier used in the snippet's URL instead of the ID
+	UserID  int    // ID of the user who created the snippet
+	Author  string // Name of the user who created the snippet, joined from the users table
 	Title   string
 	Content string
-	Created time.Time
-	Expires time.Time
+	// Format is one of Formats, and decides how the content is shown
+	Format string
+	// Language is one of Languages, and decides how the content is highlighted
+	Language string
+	Created  time.Time
+	Expires  time.Time
+	// Visibility is one of Visibilities, and decides who can see the snippet
+	Visibility string
+	// HashedPassword is the bcrypt hash of the password needed to read the snippet, or nil if it doesn't have one
+	HashedPassword []byte
+	ParentID       int // ID of the snippet this one was forked from, or 0 if it wasn't forked
+	Forks          int // Number of unexpired, non-private snippets forked from this one
+}
+
+// Revision is a saved version of a snippet's title and content. A revision is added each time a snippet is
+// created or its title or content is changed, so the newest revision always matches the snippet.
+type Revision struct {
+	ID        int
+	SnippetID int
+	UserID    int    // ID of the user who saved the revision
+	Author    string // Name of the user who saved the revision, joined from the users table
+	Title     string
+	Content   string
+	Created   time.Time
 }
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
-	Active         bool
+	ID               int
+	Name             string
+	Email            string
+	HashedPassword   []byte
+	Created          time.Time
+	Active           bool
+	Verified         bool // Whether the user has verified their email address
+	TwoFactorEnabled bool
+	Role             string
 }
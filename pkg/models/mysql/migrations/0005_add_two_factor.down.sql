@@ -0,0 +1,5 @@
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN totp_counter,
+    DROP COLUMN totp_secret;
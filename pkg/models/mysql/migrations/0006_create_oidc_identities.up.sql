@@ -0,0 +1,10 @@
+-- Links a user to their account at an OpenID Connect identity provider, which is identified by the issuer and
+-- the subject (the provider's ID for the user).
+CREATE TABLE oidc_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (issuer, subject),
+    CONSTRAINT oidc_identities_fk_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
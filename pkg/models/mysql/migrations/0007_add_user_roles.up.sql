@@ -0,0 +1,2 @@
+-- One of user, moderator or admin, see models.Roles.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
@@ -0,0 +1,7 @@
+-- The random identifier used in snippet URLs, see models.NewSlug. Existing snippets are given a random slug of
+-- the same length and entropy: 9 random bytes, base64url encoded to 12 characters.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = REPLACE(REPLACE(TO_BASE64(RANDOM_BYTES(9)), '+', '-'), '/', '_');
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,2 @@
+-- The language the snippet is highlighted as, one of models.Languages.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(20) NOT NULL DEFAULT 'plaintext';
@@ -0,0 +1,2 @@
+-- How the snippet's content is shown, one of models.Formats. Existing snippets are all code.
+ALTER TABLE snippets ADD COLUMN format VARCHAR(10) NOT NULL DEFAULT 'code';
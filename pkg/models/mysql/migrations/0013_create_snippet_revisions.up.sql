@@ -0,0 +1,15 @@
+-- Every saved version of a snippet's title and content, see models.Revision.
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE,
+    CONSTRAINT snippet_revisions_fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- The current version of each existing snippet becomes its first revision.
+INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, created FROM snippets;
@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY snippets_fk_parent_id;
+
+ALTER TABLE snippets DROP COLUMN parent_id;
@@ -0,0 +1,3 @@
+-- The snippet this one was forked from, or NULL if it wasn't forked, see SnippetModel.Fork.
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL,
+    ADD CONSTRAINT snippets_fk_parent_id FOREIGN KEY (parent_id) REFERENCES snippets (id) ON DELETE SET NULL;
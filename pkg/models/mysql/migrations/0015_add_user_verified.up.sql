@@ -0,0 +1,7 @@
+-- Whether the user has verified their email address, kept apart from active so that an admin can deactivate a
+-- verified user. Every existing user is treated as verified, including inactive ones: before this column an
+-- inactive user was either a signup waiting for verification or a user an admin had deactivated, and the two
+-- can't be told apart. Treating them all as unverified would let a deactivated user reactivate themselves with a
+-- new verification link, so inactive users stay deactivated instead, and an admin can reactivate a signup from
+-- /admin.
+ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// AuthenticateOIDC function returns the ID of the user who has signed in through an OpenID Connect provider. A
+// user already linked to the provider's issuer and subject is returned directly. Otherwise the user with the
+// same email address is linked, or a new active user is created if there isn't one, so the provider must have
+// verified the email. An unverified user with the email address is taken over by the provider's user. If the
+// user has been deactivated, return the models.ErrInactiveAccount error.
+func (m *UserModel) AuthenticateOIDC(issuer, subject, name, email string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var active bool
+	stmt := `SELECT users.id, users.active FROM oidc_identities
+	JOIN users ON users.id = oidc_identities.user_id
+	WHERE oidc_identities.issuer = ? AND oidc_identities.subject = ?`
+	err = tx.QueryRow(stmt, issuer, subject).Scan(&id, &active)
+	if err == nil {
+		if !active {
+			return 0, models.ErrInactiveAccount
+		}
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var verified bool
+	err = tx.QueryRow("SELECT id, active, verified FROM users WHERE email = ?", email).Scan(&id, &active, &verified)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// New users can't log in with a password until they set one with a password reset.
+		hashedPassword, err := models.RandomPasswordHash()
+		if err != nil {
+			return 0, err
+		}
+		stmt = `INSERT INTO users (name, email, hashed_password, created, active, verified)
+		VALUES(?, ?, ?, UTC_TIMESTAMP(), TRUE, TRUE)`
+		result, err := tx.Exec(stmt, name, email, string(hashedPassword))
+		if err != nil {
+			return 0, err
+		}
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		id = int(newID)
+	case err != nil:
+		return 0, err
+	case !verified:
+		// Whoever signed up with the address never proved they own it, so the provider's user claims the
+		// account, and the password set at signup stops working.
+		hashedPassword, err := models.RandomPasswordHash()
+		if err != nil {
+			return 0, err
+		}
+		stmt = "UPDATE users SET name = ?, hashed_password = ?, active = TRUE, verified = TRUE WHERE id = ?"
+		_, err = tx.Exec(stmt, name, string(hashedPassword), id)
+		if err != nil {
+			return 0, err
+		}
+	case !active:
+		return 0, models.ErrInactiveAccount
+	}
+
+	stmt = "INSERT INTO oidc_identities (issuer, subject, user_id, created) VALUES(?, ?, ?, UTC_TIMESTAMP())"
+	_, err = tx.Exec(stmt, issuer, subject, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
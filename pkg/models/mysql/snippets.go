@@ -7,39 +7,86 @@ import (
 	"github.com/rlr524/snippetbox/pkg/models"
 )
 
+// Check at compile time that the models in this package implement the storage interfaces.
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
 // SnippetModel type which wraps a sql.DB connection pool
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-// Insert function inserts a new snippet into the database
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// Insert function inserts a new snippet into the database, owned by the user with the given userID, and returns
+// the random slug it was given. If password isn't empty, the snippet can only be read with it.
+func (m *SnippetModel) Insert(title, content, format, language, expires, visibility, password string,
+	userID int) (string, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	hashedPassword, err := models.HashSnippetPassword(password)
+	if err != nil {
+		return "", err
+	}
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// The snippet and its first revision are inserted in a transaction, so there's never a snippet without one.
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
+	stmt := `INSERT INTO snippets (user_id, slug, title, content, format, language, created, expires, visibility,
+hashed_password)
+VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?)`
+
+	_, err = tx.Exec(stmt, userID, slug, title, content, format, language, expires, visibility, hashedPassword)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	return int(id), nil
+	err = insertFirstRevision(tx, slug)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return slug, nil
 }
 
-// Get function returns a specific snippet based on its ID
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+// Get function returns a specific snippet based on its ID, if the viewer can see it
+func (m *SnippetModel) Get(id, viewerID int) (*models.Snippet, error) {
+	return m.get("s.id = ?", id, viewerID)
+}
+
+// GetBySlug function returns a specific snippet based on the slug in its URL, if the viewer can see it
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	return m.get("s.slug = ?", slug, viewerID)
+}
+
+// The get method returns the snippet matching a condition on one of its columns, with the value for the condition's
+// placeholder. The condition is always a constant from the calling method, never user input.
+func (m *SnippetModel) get(condition string, value interface{}, viewerID int) (*models.Snippet, error) {
 	// SQL statement to execute
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	// The snippet is joined to the users table so that the author's name is returned along with the snippet.
+	// Private snippets are only returned to their author.
+	stmt := `SELECT s.id, s.slug, s.user_id, u.name, s.title, s.content, s.format, s.language, s.created, s.expires,
+s.visibility, s.hashed_password, COALESCE(s.parent_id, 0),
+(SELECT COUNT(*) FROM snippets f WHERE f.parent_id = s.id AND f.expires > UTC_TIMESTAMP() AND f.visibility <> 'private')
+FROM snippets s
+INNER JOIN users u ON u.id = s.user_id
+WHERE s.expires > UTC_TIMESTAMP() AND ` + condition + ` AND (s.visibility <> 'private' OR s.user_id = ?)`
 
-	// Use QueryRow() method on the connection pool to execute the statement, passing in the untrusted id
-	// variable as the value for the placeholder parameter. This returns a pointer to a sql.Row object which
+	// Use QueryRow() method on the connection pool to execute the statement, passing in the untrusted value
+	// as the value for the placeholder parameter. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRow(stmt, value, viewerID)
 
 	// Initialize a pointer to a new zeroed Snippet struct
 	s := &models.Snippet{}
@@ -49,7 +96,8 @@ WHERE expires > UTC_TIMESTAMP() AND id = ?`
 	// the number of arguments must be exactly the same as the number of columns returned by the statement.
 	// The must also EXACTLY MATCH the order of the fields in the model and the order in which the fields are
 	// returned in the statement or the data will be populated into the wrong field when displayed.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Format, &s.Language, &s.Created,
+		&s.Expires, &s.Visibility, &s.HashedPassword, &s.ParentID, &s.Forks)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a sql.ErrNoRows error. We use the errors.Is()
 		// function to check for that error specifically, and return our own models.ErrNoRecord instead
@@ -63,12 +111,17 @@ WHERE expires > UTC_TIMESTAMP() AND id = ?`
 	return s, nil
 }
 
-// Latest function returns the 10 most recently created snippets
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC limit 20`
+// Latest function returns the 20 most recently created public snippets, along with the viewer's own snippets
+func (m *SnippetModel) Latest(viewerID int) ([]*models.Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.user_id, u.name, s.title, s.content, s.format, s.language, s.created, s.expires,
+s.visibility, s.hashed_password, COALESCE(s.parent_id, 0),
+(SELECT COUNT(*) FROM snippets f WHERE f.parent_id = s.id AND f.expires > UTC_TIMESTAMP() AND f.visibility <> 'private')
+FROM snippets s
+INNER JOIN users u ON u.id = s.user_id
+WHERE s.expires > UTC_TIMESTAMP() AND (s.visibility = 'public' OR s.user_id = ?)
+ORDER BY s.created DESC limit 20`
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +132,8 @@ WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC limit 20`
 
 	for rows.Next() {
 		s := &models.Snippet{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Format, &s.Language, &s.Created,
+			&s.Expires, &s.Visibility, &s.HashedPassword, &s.ParentID, &s.Forks)
 		if err != nil {
 			return nil, err
 		}
@@ -92,3 +146,209 @@ WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC limit 20`
 
 	return snippets, nil
 }
+
+// Update function replaces the title, content, format, language and visibility of a specific snippet based on its
+// ID, and its password unless password is nil. If the title or content changed, a new revision is saved in the
+// same transaction.
+func (m *SnippetModel) Update(id int, title, content, format, language, visibility string, password *string) error {
+	// The bcrypt hash is slow, so it's made before the transaction starts.
+	var hashedPassword []byte
+	if password != nil {
+		var err error
+		hashedPassword, err = models.HashSnippetPassword(*password)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent string
+	err = tx.QueryRow(`SELECT title, content FROM snippets WHERE id = ?`, id).Scan(&oldTitle, &oldContent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, format = ?, language = ?, visibility = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, title, content, format, language, visibility, id)
+	if err != nil {
+		return err
+	}
+
+	if password != nil {
+		_, err = tx.Exec(`UPDATE snippets SET hashed_password = ? WHERE id = ?`, hashedPassword, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if title != oldTitle || content != oldContent {
+		stmt = `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, UTC_TIMESTAMP() FROM snippets WHERE id = ?`
+
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Fork function copies a specific snippet, based on its ID, to a new snippet owned by the user with the given
+// userID, and returns the random slug the copy was given. The copy keeps the visibility and password of the
+// original, records it as its parent and expires after the given number of days.
+func (m *SnippetModel) Fork(id, userID int, expires string) (string, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, slug, parent_id, title, content, format, language, created, expires,
+visibility, hashed_password)
+SELECT ?, ?, id, title, content, format, language, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY),
+visibility, hashed_password FROM snippets WHERE id = ?`
+
+	result, err := tx.Exec(stmt, userID, slug, expires, id)
+	if err != nil {
+		return "", err
+	}
+
+	// If no rows were inserted then there was no snippet with a matching ID, so return models.ErrNoRecord
+	n, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", models.ErrNoRecord
+	}
+
+	err = insertFirstRevision(tx, slug)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return slug, nil
+}
+
+// The insertFirstRevision function saves the title and content of a newly inserted snippet as its first revision,
+// in the transaction which inserted the snippet. The revision is copied from the snippet, so it has the same
+// created time.
+func insertFirstRevision(tx *sql.Tx, slug string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, created FROM snippets WHERE slug = ?`
+
+	_, err := tx.Exec(stmt, slug)
+	return err
+}
+
+// Delete function removes a specific snippet based on its ID
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	// If no rows were affected then there was no snippet with a matching ID, so return models.ErrNoRecord
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// DeleteBySlug function removes a specific snippet based on its slug. Unlike GetBySlug it doesn't check whether
+// the snippet can be seen, so moderators can remove any snippet, including private and expired ones.
+func (m *SnippetModel) DeleteBySlug(slug string) error {
+	stmt := `DELETE FROM snippets WHERE slug = ?`
+
+	result, err := m.DB.Exec(stmt, slug)
+	if err != nil {
+		return err
+	}
+
+	// If no rows were affected then there was no snippet with a matching ID, so return models.ErrNoRecord
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Revisions function returns every revision of a specific snippet based on its ID, newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created FROM snippet_revisions r
+INNER JOIN users u ON u.id = r.user_id
+WHERE r.snippet_id = ?
+ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		r := &models.Revision{}
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision function returns a specific revision of a specific snippet based on their IDs
+func (m *SnippetModel) Revision(id, revisionID int) (*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created FROM snippet_revisions r
+INNER JOIN users u ON u.id = r.user_id
+WHERE r.snippet_id = ? AND r.id = ?`
+
+	r := &models.Revision{}
+	err := m.DB.QueryRow(stmt, id, revisionID).Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content,
+		&r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// TokenModel type which wraps a sql.DB connection pool
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert function issues a new token for a user with the given scopes, returning the plain-text token. Only the
+// hash of the token is stored, so this is the only time the plain-text token is available.
+func (m *TokenModel) Insert(userID int, name string, scopes []string) (string, error) {
+	plaintext, hash, err := models.NewTokenPlaintext()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, created) VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash, strings.Join(scopes, ","))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Authenticate function returns the token matching a plain-text token. If there is no matching token, or the user
+// it belongs to is not active, return the ErrInvalidCredentials error.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	stmt := `SELECT t.id, t.user_id, t.name, t.scopes, t.created FROM tokens t
+INNER JOIN users u ON u.id = t.user_id
+WHERE t.hash = ? AND u.active = TRUE`
+
+	row := m.DB.QueryRow(stmt, models.HashToken(plaintext))
+
+	t := &models.Token{}
+	var scopes string
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidCredentials
+		} else {
+			return nil, err
+		}
+	}
+	t.Scopes = splitScopes(scopes)
+	return t, nil
+}
+
+// List function returns all the tokens belonging to a user, newest first
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+	stmt := `SELECT id, user_id, name, scopes, created FROM tokens WHERE user_id = ? ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+
+	for rows.Next() {
+		t := &models.Token{}
+		var scopes string
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		t.Scopes = splitScopes(scopes)
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke function deletes a token. The user ID is part of the statement so that a user can only revoke their
+// own tokens; if no token matches, return models.ErrNoRecord.
+func (m *TokenModel) Revoke(id, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// The splitScopes function converts the comma separated scopes column back into a slice.
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
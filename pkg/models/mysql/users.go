@@ -7,6 +7,7 @@ import (
 	"github.com/rlr524/snippetbox/pkg/models"
 	"golang.org/x/crypto/bcrypt"
 	"strings"
+	"time"
 )
 
 type UserModel struct {
@@ -14,13 +15,14 @@ type UserModel struct {
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	// Retrieve the id and hashed password of the user. If no matching email exists, or the user is not
-	// active, return the ErrInvalidCredentials error.
+	// Retrieve the id, hashed password, active and verified status of the user. If no matching email exists,
+	// return the ErrInvalidCredentials error.
 	var id int
 	var hashedPassword []byte
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ? AND active = TRUE"
+	var active, verified bool
+	stmt := "SELECT id, hashed_password, active, verified FROM users WHERE email = ?"
 	row := m.DB.QueryRow(stmt, email)
-	err := row.Scan(&id, &hashedPassword)
+	err := row.Scan(&id, &hashedPassword, &active, &verified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, models.ErrInvalidCredentials
@@ -39,6 +41,15 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 			return 0, err
 		}
 	}
+
+	// Only once the password is known to be right, tell the caller that the email address hasn't been verified
+	// or that the account has been deactivated.
+	if !verified {
+		return 0, models.ErrUnverifiedAccount
+	}
+	if !active {
+		return 0, models.ErrInactiveAccount
+	}
 	return id, nil
 }
 
@@ -47,17 +58,20 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 // MySQL to not change error codes or messages in future versions if we upgrade or on updating this function. Neither
 // option is perfect, however it seems like a better option to not couple so tightly to this specific version of MySQL.
 
-func (m *UserModel) Insert(name, email, password string) error {
+// Insert function creates a new user, which stays inactive until their email address has been verified, and
+// returns their ID.
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	// Create a bcrypt hash of the plain-text password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, hashed_password, created, active, verified)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), FALSE, FALSE)`
 
 	// Use the Exec() method to insert the user details and hashed password into the users table
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := m.DB.Exec(stmt, name, email, string(hashedPassword))
 	if err != nil {
 		// If this returns an error, we use the errors.As() function to check whether the error has the
 		// type *mysql.MySQLError. If it does, the error will be assigned to the mySQLError variable. We
@@ -66,18 +80,254 @@ func (m *UserModel) Insert(name, email, password string) error {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return models.ErrDuplicateEmail
+				return 0, models.ErrDuplicateEmail
 			}
 		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Activate function marks the user with the given ID as verified and active, once they've verified their email
+// address. Only a user who hasn't verified it yet is changed, so a user an admin has deactivated can't turn their
+// account back on with the link. If there is no such unverified user, return the models.ErrNoRecord error.
+func (m *UserModel) Activate(id int) error {
+	stmt := "UPDATE users SET active = TRUE, verified = TRUE WHERE id = ? AND verified = FALSE"
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
 	return nil
 }
 
+// UnverifiedID function returns the ID of the user with the given email address, if they haven't verified it
+// yet, so that a new verification link can be sent. Otherwise return the models.ErrNoRecord error.
+func (m *UserModel) UnverifiedID(email string) (int, error) {
+	var id int
+	stmt := "SELECT id FROM users WHERE email = ? AND verified = FALSE"
+	err := m.DB.QueryRow(stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// Exists function reports whether there is an active user with the given ID. It's used to check that the user
+// in a session hasn't been deleted or deactivated since they logged in.
 func (m *UserModel) Exists(id int) (bool, error) {
-	return false, nil
+	var exists bool
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND active = TRUE)"
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
 }
 
+// Get function returns a specific user based on their ID. The hashed password isn't retrieved.
 func (m *UserModel) Get(id int) (*models.User, error) {
-	return nil, nil
+	u := &models.User{}
+	stmt := "SELECT id, name, email, created, active, verified, totp_secret IS NOT NULL, role FROM users WHERE id = ?"
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.Verified,
+		&u.TwoFactorEnabled, &u.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// ChangePassword function replaces the password of a user after checking that currentPassword is their current
+// password, and deletes any password reset tokens issued to them. If it isn't, return the ErrInvalidCredentials
+// error.
+func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+	err := m.DB.QueryRow(stmt, id).Scan(&currentHashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt = "UPDATE users SET hashed_password = ? WHERE id = ?"
+	_, err = tx.Exec(stmt, string(newHashedPassword), id)
+	if err != nil {
+		return err
+	}
+
+	// A reset link sent before the change mustn't be able to undo it.
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreatePasswordReset function issues a password reset token for the active user with the given email, which
+// expires after ttl. Only the hash of the token is stored, the plain-text token is returned to be emailed to the
+// user. If there is no active user with the email, return models.ErrNoRecord.
+func (m *UserModel) CreatePasswordReset(email string, ttl time.Duration) (string, error) {
+	var id int
+	stmt := "SELECT id FROM users WHERE email = ? AND active = TRUE"
+	err := m.DB.QueryRow(stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	plaintext, hash, err := models.NewTokenPlaintext()
+	if err != nil {
+		return "", err
+	}
+
+	stmt = `INSERT INTO password_resets (hash, user_id, expires)
+VALUES(?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+	_, err = m.DB.Exec(stmt, hash, id, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// ResetPassword function sets a new password for the user a password reset token was issued to. The tokens are
+// single-use: all of the user's reset tokens are deleted in the same transaction as the password is changed. If
+// the token doesn't exist or has expired, return the models.ErrInvalidToken error.
+func (m *UserModel) ResetPassword(token, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the token row so that two concurrent requests can't both use it.
+	var userID int
+	stmt := "SELECT user_id FROM password_resets WHERE hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE"
+	err = tx.QueryRow(stmt, models.HashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrInvalidToken
+		} else {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", string(hashedPassword), userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// List function returns every user, in the order they signed up. The hashed passwords aren't retrieved.
+func (m *UserModel) List() ([]*models.User, error) {
+	stmt := "SELECT id, name, email, created, active, verified, totp_secret IS NOT NULL, role FROM users ORDER BY id"
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*models.User{}
+
+	for rows.Next() {
+		u := &models.User{}
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.Verified, &u.TwoFactorEnabled,
+			&u.Role)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SetActive function activates or deactivates the user with the given ID. Inactive users can't log in, and are
+// logged out of any sessions they have. If there is no such user, return the models.ErrNoRecord error.
+func (m *UserModel) SetActive(id int, active bool) error {
+	return m.updateUser("UPDATE users SET active = ? WHERE id = ?", id, active)
+}
+
+// SetRole function changes the role of the user with the given ID. If there is no such user, return the
+// models.ErrNoRecord error.
+func (m *UserModel) SetRole(id int, role string) error {
+	return m.updateUser("UPDATE users SET role = ? WHERE id = ?", id, role)
+}
+
+// The updateUser method runs an UPDATE statement for the user with the given ID, which is passed as the last
+// argument. MySQL only counts the rows which were actually changed as affected, so whether the user exists is
+// checked first. If there is no such user, return the models.ErrNoRecord error.
+func (m *UserModel) updateUser(stmt string, id int, args ...interface{}) error {
+	var exists bool
+	err := m.DB.QueryRow("SELECT EXISTS(SELECT true FROM users WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrNoRecord
+	}
+
+	_, err = m.DB.Exec(stmt, append(args, id)...)
+	return err
 }
@@ -0,0 +1,13 @@
+package postgres
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations holds the versioned SQL migrations which create the PostgreSQL schema used by this package. They're
+// embedded in the binary and applied with the migrate package.
+var Migrations, _ = fs.Sub(migrations, "migrations")
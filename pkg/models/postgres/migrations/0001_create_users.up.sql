@@ -0,0 +1,11 @@
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- Email addresses are unique regardless of case, as they are with the default MySQL collation.
+CREATE UNIQUE INDEX users_uc_email ON users (LOWER(email));
@@ -0,0 +1,10 @@
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    expires TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets (created);
@@ -0,0 +1,8 @@
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    created TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
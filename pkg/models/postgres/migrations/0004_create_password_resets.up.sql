@@ -0,0 +1,5 @@
+CREATE TABLE password_resets (
+    hash BYTEA PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
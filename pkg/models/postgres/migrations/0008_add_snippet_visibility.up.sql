@@ -0,0 +1,2 @@
+-- One of public, unlisted or private, see models.Visibilities.
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
@@ -0,0 +1,10 @@
+-- The random identifier used in snippet URLs, see models.NewSlug. Existing snippets are given a random slug of
+-- the same length and entropy: 9 random bytes, base64url encoded to 12 characters. The bytes are taken from
+-- version 4 UUIDs, whose first 6 bytes are all random, so that the pgcrypto extension isn't needed.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = translate(encode(
+    substring(decode(replace(gen_random_uuid()::text, '-', ''), 'hex') FROM 1 FOR 6) ||
+    substring(decode(replace(gen_random_uuid()::text, '-', ''), 'hex') FROM 1 FOR 3), 'base64'), '+/', '-_');
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,15 @@
+-- Every saved version of a snippet's title and content, see models.Revision.
+CREATE TABLE snippet_revisions (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions (snippet_id);
+
+-- The current version of each existing snippet becomes its first revision.
+INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, created FROM snippets;
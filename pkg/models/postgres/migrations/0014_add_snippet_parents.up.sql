@@ -0,0 +1,4 @@
+-- The snippet this one was forked from, or NULL if it wasn't forked, see SnippetModel.Fork.
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL REFERENCES snippets (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_parent_id ON snippets (parent_id);
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// AuthenticateOIDC function returns the ID of the user who has signed in through an OpenID Connect provider. A
+// user already linked to the provider's issuer and subject is returned directly. Otherwise the user with the
+// same email address is linked, or a new active user is created if there isn't one, so the provider must have
+// verified the email. An unverified user with the email address is taken over by the provider's user. If the
+// user has been deactivated, return the models.ErrInactiveAccount error.
+func (m *UserModel) AuthenticateOIDC(issuer, subject, name, email string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var active bool
+	stmt := `SELECT users.id, users.active FROM oidc_identities
+	JOIN users ON users.id = oidc_identities.user_id
+	WHERE oidc_identities.issuer = $1 AND oidc_identities.subject = $2`
+	err = tx.QueryRow(stmt, issuer, subject).Scan(&id, &active)
+	if err == nil {
+		if !active {
+			return 0, models.ErrInactiveAccount
+		}
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var verified bool
+	stmt = "SELECT id, active, verified FROM users WHERE LOWER(email) = LOWER($1)"
+	err = tx.QueryRow(stmt, email).Scan(&id, &active, &verified)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// New users can't log in with a password until they set one with a password reset.
+		hashedPassword, err := models.RandomPasswordHash()
+		if err != nil {
+			return 0, err
+		}
+		stmt = `INSERT INTO users (name, email, hashed_password, created, active, verified)
+		VALUES($1, $2, $3, NOW(), TRUE, TRUE) RETURNING id`
+		err = tx.QueryRow(stmt, name, email, string(hashedPassword)).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	case !verified:
+		// Whoever signed up with the address never proved they own it, so the provider's user claims the
+		// account, and the password set at signup stops working.
+		hashedPassword, err := models.RandomPasswordHash()
+		if err != nil {
+			return 0, err
+		}
+		stmt = "UPDATE users SET name = $1, hashed_password = $2, active = TRUE, verified = TRUE WHERE id = $3"
+		_, err = tx.Exec(stmt, name, string(hashedPassword), id)
+		if err != nil {
+			return 0, err
+		}
+	case !active:
+		return 0, models.ErrInactiveAccount
+	}
+
+	stmt = "INSERT INTO oidc_identities (issuer, subject, user_id, created) VALUES($1, $2, $3, NOW())"
+	_, err = tx.Exec(stmt, issuer, subject, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
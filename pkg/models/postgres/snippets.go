@@ -0,0 +1,340 @@
+// Package postgres implements the storage interfaces from the models package using PostgreSQL, through the
+// lib/pq driver.
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// Check at compile time that the models in this package implement the storage interfaces.
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// SnippetModel type which wraps a sql.DB connection pool
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// Insert function inserts a new snippet into the database, owned by the user with the given userID, and returns
+// the random slug it was given. If password isn't empty, the snippet can only be read with it.
+func (m *SnippetModel) Insert(title, content, format, language, expires, visibility, password string,
+	userID int) (string, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	hashedPassword, err := models.HashSnippetPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	// The snippet and its first revision are inserted in a transaction, so there's never a snippet without one.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, slug, title, content, format, language, created, expires, visibility,
+hashed_password)
+VALUES($1, $2, $3, $4, $5, $6, NOW(), NOW() + make_interval(days => $7), $8, $9)`
+
+	_, err = tx.Exec(stmt, userID, slug, title, content, format, language, expires, visibility, hashedPassword)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertFirstRevision(tx, slug)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return slug, nil
+}
+
+// Get function returns a specific snippet based on its ID, if the viewer can see it
+func (m *SnippetModel) Get(id, viewerID int) (*models.Snippet, error) {
+	return m.get("s.id = $1", id, viewerID)
+}
+
+// GetBySlug function returns a specific snippet based on the slug in its URL, if the viewer can see it
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	return m.get("s.slug = $1", slug, viewerID)
+}
+
+// The get method returns the snippet matching a condition on one of its columns, with the value for the condition's
+// $1 placeholder. The condition is always a constant from the calling method, never user input. Private snippets
+// are only returned to their author.
+func (m *SnippetModel) get(condition string, value interface{}, viewerID int) (*models.Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.user_id, u.name, s.title, s.content, s.format, s.language, s.created, s.expires,
+s.visibility, s.hashed_password, COALESCE(s.parent_id, 0),
+(SELECT COUNT(*) FROM snippets f WHERE f.parent_id = s.id AND f.expires > NOW() AND f.visibility <> 'private')
+FROM snippets s
+INNER JOIN users u ON u.id = s.user_id
+WHERE s.expires > NOW() AND ` + condition + ` AND (s.visibility <> 'private' OR s.user_id = $2)`
+
+	row := m.DB.QueryRow(stmt, value, viewerID)
+
+	s := &models.Snippet{}
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Format, &s.Language, &s.Created,
+		&s.Expires, &s.Visibility, &s.HashedPassword, &s.ParentID, &s.Forks)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Latest function returns the 20 most recently created public snippets, along with the viewer's own snippets
+func (m *SnippetModel) Latest(viewerID int) ([]*models.Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.user_id, u.name, s.title, s.content, s.format, s.language, s.created, s.expires,
+s.visibility, s.hashed_password, COALESCE(s.parent_id, 0),
+(SELECT COUNT(*) FROM snippets f WHERE f.parent_id = s.id AND f.expires > NOW() AND f.visibility <> 'private')
+FROM snippets s
+INNER JOIN users u ON u.id = s.user_id
+WHERE s.expires > NOW() AND (s.visibility = 'public' OR s.user_id = $1)
+ORDER BY s.created DESC LIMIT 20`
+
+	rows, err := m.DB.Query(stmt, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Format, &s.Language, &s.Created,
+			&s.Expires, &s.Visibility, &s.HashedPassword, &s.ParentID, &s.Forks)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Update function replaces the title, content, format, language and visibility of a specific snippet based on its
+// ID, and its password unless password is nil. If the title or content changed, a new revision is saved in the
+// same transaction.
+func (m *SnippetModel) Update(id int, title, content, format, language, visibility string, password *string) error {
+	// The bcrypt hash is slow, so it's made before the transaction starts.
+	var hashedPassword []byte
+	if password != nil {
+		var err error
+		hashedPassword, err = models.HashSnippetPassword(*password)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent string
+	err = tx.QueryRow(`SELECT title, content FROM snippets WHERE id = $1`, id).Scan(&oldTitle, &oldContent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+
+	stmt := `UPDATE snippets SET title = $1, content = $2, format = $3, language = $4, visibility = $5
+WHERE id = $6`
+
+	_, err = tx.Exec(stmt, title, content, format, language, visibility, id)
+	if err != nil {
+		return err
+	}
+
+	if password != nil {
+		_, err = tx.Exec(`UPDATE snippets SET hashed_password = $1 WHERE id = $2`, hashedPassword, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if title != oldTitle || content != oldContent {
+		stmt = `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, NOW() FROM snippets WHERE id = $1`
+
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Fork function copies a specific snippet, based on its ID, to a new snippet owned by the user with the given
+// userID, and returns the random slug the copy was given. The copy keeps the visibility and password of the
+// original, records it as its parent and expires after the given number of days.
+func (m *SnippetModel) Fork(id, userID int, expires string) (string, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, slug, parent_id, title, content, format, language, created, expires,
+visibility, hashed_password)
+SELECT $1, $2, id, title, content, format, language, NOW(), NOW() + make_interval(days => $3), visibility,
+hashed_password FROM snippets WHERE id = $4`
+
+	result, err := tx.Exec(stmt, userID, slug, expires, id)
+	if err != nil {
+		return "", err
+	}
+
+	// If no rows were inserted then there was no snippet with a matching ID, so return models.ErrNoRecord
+	n, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", models.ErrNoRecord
+	}
+
+	err = insertFirstRevision(tx, slug)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+	return slug, nil
+}
+
+// The insertFirstRevision function saves the title and content of a newly inserted snippet as its first revision,
+// in the transaction which inserted the snippet. The revision is copied from the snippet, so it has the same
+// created time.
+func insertFirstRevision(tx *sql.Tx, slug string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+SELECT id, user_id, title, content, created FROM snippets WHERE slug = $1`
+
+	_, err := tx.Exec(stmt, slug)
+	return err
+}
+
+// Delete function removes a specific snippet based on its ID
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = $1`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// DeleteBySlug function removes a specific snippet based on its slug. Unlike GetBySlug it doesn't check whether
+// the snippet can be seen, so moderators can remove any snippet, including private and expired ones.
+func (m *SnippetModel) DeleteBySlug(slug string) error {
+	stmt := `DELETE FROM snippets WHERE slug = $1`
+
+	result, err := m.DB.Exec(stmt, slug)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Revisions function returns every revision of a specific snippet based on its ID, newest first
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created FROM snippet_revisions r
+INNER JOIN users u ON u.id = r.user_id
+WHERE r.snippet_id = $1
+ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		r := &models.Revision{}
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision function returns a specific revision of a specific snippet based on their IDs
+func (m *SnippetModel) Revision(id, revisionID int) (*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created FROM snippet_revisions r
+INNER JOIN users u ON u.id = r.user_id
+WHERE r.snippet_id = $1 AND r.id = $2`
+
+	r := &models.Revision{}
+	err := m.DB.QueryRow(stmt, id, revisionID).Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content,
+		&r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return r, nil
+}
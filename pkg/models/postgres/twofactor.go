@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// TOTPSecret function returns the secret used to check the two-factor codes of a user, or an empty string if
+// they haven't enabled two-factor login. If there is no such user, return the models.ErrNoRecord error.
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	var secret sql.NullString
+	err := m.DB.QueryRow("SELECT totp_secret FROM users WHERE id = $1", id).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+	return secret.String, nil
+}
+
+// EnableTwoFactor function turns on two-factor login for a user with a confirmed TOTP secret. The recovery codes
+// replace any the user had before, and only their hashes are stored.
+func (m *UserModel) EnableTwoFactor(id int, secret string, recoveryCodes []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = $1, totp_counter = 0 WHERE id = $2", secret, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, hash) VALUES($1, $2)", id,
+			models.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DisableTwoFactor function turns off two-factor login for a user and removes their recovery codes
+func (m *UserModel) DisableTwoFactor(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = NULL, totp_counter = 0 WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPCounter function records that a two-factor code for the given TOTP time step has been accepted. Codes
+// for the same or an earlier time step can't be used again, so if one already has been, return the
+// models.ErrInvalidToken error.
+func (m *UserModel) UseTOTPCounter(id int, counter int64) error {
+	stmt := "UPDATE users SET totp_counter = $1 WHERE id = $2 AND totp_counter < $3"
+	result, err := m.DB.Exec(stmt, counter, id, counter)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// UseRecoveryCode function deletes one of a user's recovery codes, so it can only be used once. If the user has
+// no such code, return the models.ErrInvalidToken error.
+func (m *UserModel) UseRecoveryCode(id int, code string) error {
+	stmt := "DELETE FROM recovery_codes WHERE user_id = $1 AND hash = $2"
+	result, err := m.DB.Exec(stmt, id, models.HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// The requireRow function returns the models.ErrInvalidToken error if a statement didn't change any rows.
+func requireRow(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+// The roles a user can have. Each role has all the permissions of the ones before it: moderators can remove any
+// snippet, and admins can also manage users.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role, from the least to the most privileged.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// ValidRole returns true if role is one of the roles a user can have.
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// HasRole returns true if a user with the given role has at least the permissions of the required role.
+func HasRole(role, required string) bool {
+	return ValidRole(role) && roleRank(role) >= roleRank(required)
+}
+
+// The roleRank function returns the position of a role in Roles, or -1 if it isn't a role.
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// NewSlug generates the random identifier used in a snippet's URL, so that snippets can't be found by counting
+// up from 1. It's 9 bytes of entropy from the operating system's CSPRNG, which base64url encodes to 12
+// characters without padding.
+func NewSlug() (string, error) {
+	b := make([]byte, 9)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashSnippetPassword returns the bcrypt hash to store for a snippet's password, using the same cost as users'
+// passwords. An empty password means the snippet has no password, which is stored as a nil hash.
+func HashSnippetPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// Protected returns true if the snippet can only be read with its password.
+func (s *Snippet) Protected() bool {
+	return len(s.HashedPassword) > 0
+}
+
+// MatchesPassword returns true if password is the snippet's password.
+func (s *Snippet) MatchesPassword(password string) bool {
+	return s.Protected() && bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password)) == nil
+}
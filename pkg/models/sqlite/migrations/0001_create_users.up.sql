@@ -0,0 +1,10 @@
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    -- Email addresses are unique regardless of case, as they are with the default MySQL collation.
+    email TEXT NOT NULL COLLATE NOCASE,
+    hashed_password TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
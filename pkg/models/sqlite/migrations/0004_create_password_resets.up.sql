@@ -0,0 +1,5 @@
+CREATE TABLE password_resets (
+    hash BLOB NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires DATETIME NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP TABLE recovery_codes;
+
+ALTER TABLE users DROP COLUMN totp_counter;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,10 @@
+-- totp_secret is only set once the user has confirmed two-factor enrollment with a code. totp_counter is the
+-- latest TOTP time step a code was accepted for, so a code can't be used twice.
+ALTER TABLE users ADD COLUMN totp_secret TEXT NULL;
+ALTER TABLE users ADD COLUMN totp_counter INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    hash BLOB NOT NULL,
+    PRIMARY KEY (user_id, hash)
+);
@@ -0,0 +1,9 @@
+-- Links a user to their account at an OpenID Connect identity provider, which is identified by the issuer and
+-- the subject (the provider's ID for the user).
+CREATE TABLE oidc_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
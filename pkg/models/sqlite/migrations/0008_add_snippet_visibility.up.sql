@@ -0,0 +1,2 @@
+-- One of public, unlisted or private, see models.Visibilities.
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
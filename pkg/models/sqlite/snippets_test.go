@@ -0,0 +1,211 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/migrate"
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+// The newTestDB function returns a database in a temporary file with every migration applied, holding two active
+// users, Alice with ID 1 and Bob with ID 2. They're added directly rather than through UserModel.Insert, so the
+// tests don't wait for bcrypt.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Alice", "Bob"} {
+		stmt := `INSERT INTO users (name, email, hashed_password, created, active, verified)
+		VALUES(?, ?, '', ?, TRUE, TRUE)`
+		_, err = db.Exec(stmt, name, name+"@example.com", timestamp(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// The insertSnippet function adds a snippet owned by userID with the given visibility and returns its slug. If
+// expired is true, the snippet's expiry is moved into the past.
+func insertSnippet(t *testing.T, db *sql.DB, title, visibility string, userID int, expired bool) string {
+	t.Helper()
+
+	m := &SnippetModel{DB: db}
+	slug, err := m.Insert(title, "content", models.FormatCode, "plaintext", "7", visibility, "", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired {
+		_, err = db.Exec("UPDATE snippets SET expires = ? WHERE slug = ?", timestamp(time.Now().Add(-time.Second)),
+			slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return slug
+}
+
+func TestSnippetModelGetBySlug(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	public := insertSnippet(t, db, "Public", models.VisibilityPublic, 1, false)
+	unlisted := insertSnippet(t, db, "Unlisted", models.VisibilityUnlisted, 1, false)
+	private := insertSnippet(t, db, "Private", models.VisibilityPrivate, 1, false)
+	expired := insertSnippet(t, db, "Expired", models.VisibilityPublic, 1, true)
+
+	tests := []struct {
+		name      string
+		slug      string
+		viewerID  int
+		wantTitle string
+		wantErr   error
+	}{
+		{"Public, anonymous", public, 0, "Public", nil},
+		{"Unlisted, other user", unlisted, 2, "Unlisted", nil},
+		{"Private, owner", private, 1, "Private", nil},
+		{"Private, other user", private, 2, "", models.ErrNoRecord},
+		{"Private, anonymous", private, 0, "", models.ErrNoRecord},
+		{"Expired, owner", expired, 1, "", models.ErrNoRecord},
+		{"Unknown slug", "AAAAAAAAAAAA", 1, "", models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.GetBySlug(tt.slug, tt.viewerID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if s.Title != tt.wantTitle {
+				t.Errorf("want title %q; got %q", tt.wantTitle, s.Title)
+			}
+			if s.Author != "Alice" {
+				t.Errorf("want author %q; got %q", "Alice", s.Author)
+			}
+		})
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	insertSnippet(t, db, "Alice public", models.VisibilityPublic, 1, false)
+	insertSnippet(t, db, "Alice unlisted", models.VisibilityUnlisted, 1, false)
+	insertSnippet(t, db, "Alice private", models.VisibilityPrivate, 1, false)
+	insertSnippet(t, db, "Alice expired", models.VisibilityPublic, 1, true)
+	insertSnippet(t, db, "Bob public", models.VisibilityPublic, 2, false)
+	insertSnippet(t, db, "Bob private", models.VisibilityPrivate, 2, false)
+
+	// Snippets created in the same second are returned newest ID first.
+	tests := []struct {
+		name       string
+		viewerID   int
+		wantTitles []string
+	}{
+		{"Anonymous", 0, []string{"Bob public", "Alice public"}},
+		{"Alice", 1, []string{"Bob public", "Alice private", "Alice unlisted", "Alice public"}},
+		{"Bob", 2, []string{"Bob private", "Bob public", "Alice public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Latest(tt.viewerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, s := range snippets {
+				titles = append(titles, s.Title)
+			}
+			if len(titles) != len(tt.wantTitles) {
+				t.Fatalf("want %q; got %q", tt.wantTitles, titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.wantTitles[i] {
+					t.Fatalf("want %q; got %q", tt.wantTitles, titles)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	none, secret := "", "s3cret"
+
+	// Each step updates the snippet and checks its content, password and number of revisions afterwards.
+	tests := []struct {
+		name          string
+		content       string
+		password      *string
+		wantPassword  string // "" for none
+		wantRevisions int
+	}{
+		{"Password set", "content", &secret, "s3cret", 1},
+		{"Content changed", "new content", nil, "s3cret", 2},
+		{"Password removed", "new content", &none, "", 2},
+	}
+
+	slug := insertSnippet(t, db, "Title", models.VisibilityPublic, 1, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.GetBySlug(slug, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.Update(s.ID, s.Title, tt.content, s.Format, s.Language, s.Visibility, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = m.GetBySlug(slug, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Content != tt.content {
+				t.Errorf("want content %q; got %q", tt.content, s.Content)
+			}
+			if tt.wantPassword == "" {
+				if s.Protected() {
+					t.Error("want no password")
+				}
+			} else if !s.MatchesPassword(tt.wantPassword) {
+				t.Errorf("want password %q", tt.wantPassword)
+			}
+
+			revisions, err := m.Revisions(s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != tt.wantRevisions {
+				t.Errorf("want %d revisions; got %d", tt.wantRevisions, len(revisions))
+			}
+		})
+	}
+}
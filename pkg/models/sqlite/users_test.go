@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rlr524/snippetbox/pkg/models"
+)
+
+func TestUserModelInsertDuplicateEmail(t *testing.T) {
+	m := &UserModel{DB: newTestDB(t)}
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{"Same email", "Alice@example.com", models.ErrDuplicateEmail},
+		{"Upper case", "ALICE@EXAMPLE.COM", models.ErrDuplicateEmail},
+		{"Different email", "carol@example.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Insert("Someone", tt.email, "pa55word1234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUserModelChangePasswordDeletesResets(t *testing.T) {
+	m := &UserModel{DB: newTestDB(t)}
+
+	id, err := m.Insert("Carol", "carol@example.com", "oldpa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Activate(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.CreatePasswordReset("carol@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.ChangePassword(id, "oldpa55word1", "newpa55word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The reset link sent before the change can't be used to set another password.
+	err = m.ResetPassword(token, "otherpa55word")
+	if !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("want error %v; got %v", models.ErrInvalidToken, err)
+	}
+}
+
+func TestUserModelAuthenticateOIDC(t *testing.T) {
+	db := newTestDB(t)
+	m := &UserModel{DB: db}
+
+	// Carol signed up but never verified her address, and Dave was deactivated after verifying his.
+	carolID, err := m.Insert("Carol", "carol@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daveID, err := m.Insert("Dave", "dave@example.com", "pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Activate(daveID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetActive(daveID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subject string
+		email   string
+		wantID  int
+		wantErr error
+	}{
+		{"Linked by email", "alice-subject", "alice@example.com", 1, nil},
+		{"Already linked", "alice-subject", "other@example.com", 1, nil},
+		{"Unverified account claimed", "carol-subject", "carol@example.com", carolID, nil},
+		{"Deactivated account", "dave-subject", "dave@example.com", 0, models.ErrInactiveAccount},
+		{"New user", "erin-subject", "erin@example.com", daveID + 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.AuthenticateOIDC("https://issuer.example.com", tt.subject, "SSO User", tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+			if id != tt.wantID {
+				t.Errorf("want user %d; got %d", tt.wantID, id)
+			}
+		})
+	}
+
+	// The claimed account is verified and active, and the password it was signed up with no longer works.
+	u, err := m.Get(carolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Active || !u.Verified {
+		t.Errorf("want claimed account active and verified; got active %t, verified %t", u.Active, u.Verified)
+	}
+	_, err = m.Authenticate("carol@example.com", "pa55word1234")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("signup password: want %v; got %v", models.ErrInvalidCredentials, err)
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// The SnippetStore interface describes the snippet operations the application needs from a storage backend.
+// Every backend (e.g. mysql.SnippetModel) implements it, which lets the handlers be used with any of them.
+// Get and Latest only return snippets the viewer can see, where the viewer is the ID of the current user, or 0
+// if nobody is logged in. Insert returns the new snippet's slug, which is used in its URL. An empty password
+// given to Insert or Update means the snippet has no password, and a nil one leaves Update's snippet with the
+// password it has. Insert and Update also save a Revision of the title and content, in the same transaction,
+// which Revisions lists newest first. Fork copies a snippet to a new one owned by userID, which records the
+// original as its parent, and returns the new snippet's slug.
+// DeleteBySlug is for moderation, so it removes the snippet whether or not it could be seen.
+type SnippetStore interface {
+	Insert(title, content, format, language, expires, visibility, password string, userID int) (string, error)
+	Get(id, viewerID int) (*Snippet, error)
+	GetBySlug(slug string, viewerID int) (*Snippet, error)
+	Latest(viewerID int) ([]*Snippet, error)
+	Update(id int, title, content, format, language, visibility string, password *string) error
+	Fork(id, userID int, expires string) (string, error)
+	Delete(id int) error
+	DeleteBySlug(slug string) error
+	Revisions(id int) ([]*Revision, error)
+	Revision(id, revisionID int) (*Revision, error)
+}
+
+// The UserStore interface describes the user operations the application needs from a storage backend.
+type UserStore interface {
+	Insert(name, email, password string) (int, error)
+	Activate(id int) error
+	UnverifiedID(email string) (int, error)
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+	ChangePassword(id int, currentPassword, newPassword string) error
+	CreatePasswordReset(email string, ttl time.Duration) (string, error)
+	ResetPassword(token, newPassword string) error
+	TOTPSecret(id int) (string, error)
+	EnableTwoFactor(id int, secret string, recoveryCodes []string) error
+	DisableTwoFactor(id int) error
+	UseTOTPCounter(id int, counter int64) error
+	UseRecoveryCode(id int, code string) error
+	AuthenticateOIDC(issuer, subject, name, email string) (int, error)
+	List() ([]*User, error)
+	SetActive(id int, active bool) error
+	SetRole(id int, role string) error
+}
+
+// The TokenStore interface describes the personal access token operations the application needs from a
+// storage backend.
+type TokenStore interface {
+	Insert(userID int, name string, scopes []string) (string, error)
+	Authenticate(plaintext string) (*Token, error)
+	List(userID int) ([]*Token, error)
+	Revoke(id, userID int) error
+}
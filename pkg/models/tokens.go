@@ -0,0 +1,66 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"time"
+)
+
+// The scopes which can be granted to a personal access token.
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+)
+
+// Scopes lists every scope which can be granted to a token, in the order they're shown on the settings page.
+var Scopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+// ValidScope returns true if scope is one of the scopes which can be granted to a token.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// A Token is a personal access token used by programmatic clients of the API. Only a hash of the token is ever
+// stored, the plain-text token is shown to the user once when it is issued.
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Scopes  []string
+	Created time.Time
+}
+
+// HasScope returns true if the token has been granted the given scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTokenPlaintext generates a new random token, returning the plain-text token to give to the user and the hash
+// of it to be stored. The token is 16 bytes of entropy from the operating system's CSPRNG, base32 encoded.
+func NewTokenPlaintext() (string, []byte, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	return plaintext, HashToken(plaintext), nil
+}
+
+// HashToken returns the SHA-256 hash of a plain-text token. A fast hash is fine here (unlike for passwords)
+// because the token itself has high entropy.
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
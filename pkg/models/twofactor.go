@@ -0,0 +1,34 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes a user is given when they enable two-factor login.
+const RecoveryCodeCount = 10
+
+// NewRecoveryCodes generates n single-use recovery codes, which let a user log in if they lose the device that
+// generates their two-factor codes. Each code is 10 random lower-case base32 characters, split in two with a
+// hyphen to make it easier to copy, e.g. "k3j5x-q7m2a".
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		codes[i] = s[:5] + "-" + s[5:10]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code to be stored or looked up. The code is normalized first,
+// so it can be typed in either case and with or without the hyphen.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashToken(code)
+}
@@ -0,0 +1,18 @@
+package models
+
+// Who can see a snippet. Public snippets are listed by Latest, unlisted snippets can be seen by anyone with the
+// link, and private snippets can only be seen by their author.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities lists every visibility a snippet can have.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// CanView returns true if the user with the given ID may see the snippet. Anonymous viewers have an ID of 0,
+// which never matches an author.
+func (s *Snippet) CanView(viewerID int) bool {
+	return s.Visibility != VisibilityPrivate || (viewerID != 0 && s.UserID == viewerID)
+}